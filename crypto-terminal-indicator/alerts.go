@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Maximum number of fired alerts kept for the UI
+const maxAlerts = 50
+
+// Structure representing an alert rules file, e.g.
+//
+//	webhook: http://localhost:8080/alerts
+//	rules:
+//	  - name: btc above 70k
+//	    symbol: btcusdt
+//	    metric: mark_price
+//	    above: 70000
+//	  - name: wide spread
+//	    metric: spread
+//	    above: 5
+//	    cooldown: 1m
+//
+// JSON is a subset of YAML, so the same file can be written in JSON as well.
+type alertConfig struct {
+	Webhook string      `yaml:"webhook"` // URL every fired alert is POSTed to, optional
+	Rules   []alertRule `yaml:"rules"`
+}
+
+// Structure representing a single alert rule.
+// A rule fires once when its metric goes above `Above` or below `Below`, and is armed again
+// once the metric is back on the other side. `Cooldown` is the minimum time between two alerts of the rule.
+type alertRule struct {
+	Name     string        `yaml:"name"`
+	Symbol   string        `yaml:"symbol"` // symbol the rule applies to, every symbol when empty
+	Metric   string        `yaml:"metric"` // one of `alertMetrics`
+	Above    *float64      `yaml:"above"`
+	Below    *float64      `yaml:"below"`
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+// Functions reading the metrics a rule can be evaluated against from a market snapshot,
+// the second return value is false while the metric isn't known yet
+var alertMetrics = map[string]func(m *marketSnapshot) (float64, bool){
+	"mark_price": func(m *marketSnapshot) (float64, bool) {
+		return m.CurrMarkPrice, m.CurrMarkPrice > 0
+	},
+	"funding_rate": func(m *marketSnapshot) (float64, bool) {
+		rate, err := strconv.ParseFloat(m.FundingRate, 64)
+		return rate, err == nil
+	},
+	"best_bid": func(m *marketSnapshot) (float64, bool) {
+		return m.BestBid.Price.Float64(), m.BestBid.Price.Sign() > 0
+	},
+	"best_ask": func(m *marketSnapshot) (float64, bool) {
+		return m.BestAsk.Price.Float64(), m.BestAsk.Price.Sign() > 0
+	},
+	"spread": func(m *marketSnapshot) (float64, bool) {
+		return m.BestAsk.Price.Sub(m.BestBid.Price).Float64(), m.BestAsk.Price.Sign() > 0 && m.BestBid.Price.Sign() > 0
+	},
+}
+
+// Structure representing a fired alert, it is also the body POSTed to the webhook
+type Alert struct {
+	Time   time.Time `json:"time"`
+	Rule   string    `json:"rule"`
+	Symbol string    `json:"symbol"`
+	Metric string    `json:"metric"`
+	Value  float64   `json:"value"`
+	Text   string    `json:"text"`
+}
+
+// Structure evaluating the alert rules against every published market snapshot.
+// `evaluate` is only called by the feed goroutine, the fired alerts are read by the render loop.
+type alertEngine struct {
+	rules   []alertRule
+	webhook string
+	client  *http.Client
+
+	// `triggered` holds the rule index and symbol of the rules whose condition currently holds,
+	// `last` the time each of them fired last
+	triggered map[alertKey]bool
+	last      map[alertKey]time.Time
+
+	mu     sync.Mutex
+	alerts []Alert // fired alerts, newest first
+	fired  int     // number of alerts fired since the start, so the UI can tell when a new one arrived
+}
+
+// Key of the state of a rule for one symbol
+type alertKey struct {
+	rule   int
+	symbol string
+}
+
+// Function to load and validate an alert rules file
+func loadAlertConfig(path string) (*alertConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg alertConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("alert rules %s: %w", path, err)
+	}
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		r.Symbol = strings.ToLower(r.Symbol)
+		if _, ok := alertMetrics[r.Metric]; !ok {
+			return nil, fmt.Errorf("alert rules %s: rule %d: unknown metric %q", path, i+1, r.Metric)
+		}
+		if r.Above == nil && r.Below == nil {
+			return nil, fmt.Errorf("alert rules %s: rule %d: one of above or below is required", path, i+1)
+		}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("%s %s", r.Metric, r.condition())
+		}
+	}
+	return &cfg, nil
+}
+
+// `newAlertEngine()` is a constructor function for creating a new instance of `alertEngine` struct
+func newAlertEngine(cfg *alertConfig, client *http.Client) *alertEngine {
+	return &alertEngine{
+		rules:     cfg.Rules,
+		webhook:   cfg.Webhook,
+		client:    client,
+		triggered: make(map[alertKey]bool),
+		last:      make(map[alertKey]time.Time),
+	}
+}
+
+// Function to describe the condition of a rule, e.g. "above 70000"
+func (r *alertRule) condition() string {
+	var conds []string
+	if r.Above != nil {
+		conds = append(conds, fmt.Sprintf("above %g", *r.Above))
+	}
+	if r.Below != nil {
+		conds = append(conds, fmt.Sprintf("below %g", *r.Below))
+	}
+	return strings.Join(conds, " or ")
+}
+
+// Function to check if the condition of a rule holds for the given value
+func (r *alertRule) holds(v float64) bool {
+	return (r.Above != nil && v > *r.Above) || (r.Below != nil && v < *r.Below)
+}
+
+// Function to evaluate every rule against a snapshot of the market.
+// A rule fires on the snapshot where its condition starts to hold, so a price hovering above
+// a level doesn't fire an alert for every update.
+func (e *alertEngine) evaluate(m *marketSnapshot) {
+	if e == nil {
+		return
+	}
+	symbol := strings.ToLower(m.Symbol)
+	for i := range e.rules {
+		r := &e.rules[i]
+		if r.Symbol != "" && r.Symbol != symbol {
+			continue
+		}
+		v, ok := alertMetrics[r.Metric](m)
+		if !ok {
+			continue
+		}
+		key := alertKey{rule: i, symbol: symbol}
+		holds := r.holds(v)
+		if !holds || e.triggered[key] {
+			e.triggered[key] = holds
+			continue
+		}
+		e.triggered[key] = true
+
+		now := time.Now()
+		if last, ok := e.last[key]; ok && now.Sub(last) < r.Cooldown {
+			continue
+		}
+		e.last[key] = now
+		e.fire(Alert{
+			Time:   now,
+			Rule:   r.Name,
+			Symbol: m.Symbol,
+			Metric: r.Metric,
+			Value:  v,
+			Text:   fmt.Sprintf("%s: %s (%s %g)", m.Symbol, r.Name, r.Metric, v),
+		})
+	}
+}
+
+// Function to record a fired alert for the UI and send it to the webhook
+func (e *alertEngine) fire(a Alert) {
+	log.Printf("alert: %s", a.Text)
+
+	e.mu.Lock()
+	e.alerts = append([]Alert{a}, e.alerts...)
+	if len(e.alerts) > maxAlerts {
+		e.alerts = e.alerts[:maxAlerts]
+	}
+	e.fired++
+	e.mu.Unlock()
+
+	if e.webhook != "" {
+		// the feed must not wait for the webhook
+		go e.post(a)
+	}
+}
+
+// Function to POST an alert as JSON to the webhook, failures are logged
+func (e *alertEngine) post(a Alert) {
+	body, err := json.Marshal(a)
+	if err != nil {
+		log.Printf("alert webhook: %v", err)
+		return
+	}
+	resp, err := e.client.Post(e.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("alert webhook: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Printf("alert webhook: unexpected status %s", resp.Status)
+	}
+}
+
+// Function to get the latest fired alerts, newest first, and the number of alerts fired so far.
+// It is safe to call from any goroutine.
+func (e *alertEngine) recent() ([]Alert, int) {
+	if e == nil {
+		return nil, 0
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Alert(nil), e.alerts...), e.fired
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// Function to start a webhook which decodes every POSTed alert and hands it to the returned channel
+func newWebhookServer(t *testing.T) (*httptest.Server, <-chan Alert) {
+	posted := make(chan Alert, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("webhook got %s, want POST", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("webhook got Content-Type %q", ct)
+		}
+		var a Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Errorf("decoding the webhook body: %v", err)
+		}
+		posted <- a
+	}))
+	t.Cleanup(srv.Close)
+	return srv, posted
+}
+
+// Function to wait for the next alert POSTed to the webhook
+func nextPosted(t *testing.T, posted <-chan Alert) Alert {
+	t.Helper()
+	select {
+	case a := <-posted:
+		return a
+	case <-time.After(5 * time.Second):
+		t.Fatal("no alert was POSTed to the webhook")
+		return Alert{}
+	}
+}
+
+func TestAlertEngineWebhook(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	srv, posted := newWebhookServer(t)
+	above, wide := 70000.0, 5.0
+	e := newAlertEngine(&alertConfig{
+		Webhook: srv.URL,
+		Rules: []alertRule{
+			{Name: "btc above 70k", Symbol: "btcusdt", Metric: "mark_price", Above: &above},
+			{Name: "wide spread", Metric: "spread", Above: &wide, Cooldown: time.Hour},
+		},
+	}, srv.Client())
+
+	snapshot := func(mark float64, bid, ask string) *marketSnapshot {
+		return &marketSnapshot{
+			Symbol:        "BTCUSDT",
+			CurrMarkPrice: mark,
+			BestBid:       OrderbookEntry{Price: dec(t, bid)},
+			BestAsk:       OrderbookEntry{Price: dec(t, ask)},
+		}
+	}
+	steps := []struct {
+		name string
+		m    *marketSnapshot
+		want []string // rules expected to fire, in rule order
+	}{
+		{"below every level", snapshot(69000, "69000", "69001"), nil},
+		{"mark price crosses", snapshot(70500, "70500", "70501"), []string{"btc above 70k"}},
+		{"still above, no repeat", snapshot(71000, "71000", "71001"), nil},
+		{"spread widens", snapshot(71000, "71000", "71010"), []string{"wide spread"}},
+		{"both clear", snapshot(69500, "69500", "69501"), nil},
+		// the mark price rule is armed again, the spread rule is still cooling down
+		{"both hold again", snapshot(70100, "70100", "70120"), []string{"btc above 70k"}},
+	}
+	fired := 0
+	for _, step := range steps {
+		e.evaluate(step.m)
+		for _, rule := range step.want {
+			a := nextPosted(t, posted)
+			if a.Rule != rule || a.Symbol != "BTCUSDT" || a.Time.IsZero() || a.Text == "" {
+				t.Errorf("%s: POSTed %+v, want rule %q", step.name, a, rule)
+			}
+			fired++
+		}
+		if alerts, n := e.recent(); n != fired {
+			t.Fatalf("%s: %d alerts fired, want %d: %+v", step.name, n, fired, alerts)
+		}
+	}
+
+	select {
+	case a := <-posted:
+		t.Errorf("unexpected alert POSTed: %+v", a)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	alerts, _ := e.recent()
+	if len(alerts) != 3 || alerts[0].Rule != "btc above 70k" || alerts[0].Metric != "mark_price" || alerts[0].Value != 70100 {
+		t.Errorf("recent alerts = %+v", alerts)
+	}
+	if got := alerts[1]; got.Metric != "spread" || got.Value != 10 {
+		t.Errorf("spread alert = %+v", got)
+	}
+}
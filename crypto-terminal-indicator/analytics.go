@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Structure representing the order flow of one window of trades
+type flowStats struct {
+	Window     time.Duration
+	VWAP       float64 // zero when there were no trades in the window
+	BuyVolume  float64 // volume bought by aggressive buyers
+	SellVolume float64 // volume sold by aggressive sellers
+}
+
+// Function to get the difference between the aggressive buy and sell volume of the window
+func (s flowStats) Delta() float64 {
+	return s.BuyVolume - s.SellVolume
+}
+
+// Function to compute the order flow of the trades within `window` before `now`.
+// The trades are ordered oldest first, so they are walked backwards until the window is left.
+func computeFlow(trades []TradeEvent, now time.Time, window time.Duration) flowStats {
+	stats := flowStats{Window: window}
+	cutoff := now.Add(-window)
+	var notional, volume float64
+	for i := len(trades) - 1; i >= 0; i-- {
+		t := trades[i]
+		if t.Time.Before(cutoff) {
+			break
+		}
+		v := t.Volume.Float64()
+		notional += t.Price.Float64() * v
+		volume += v
+		if t.BuyerMaker {
+			stats.SellVolume += v
+		} else {
+			stats.BuyVolume += v
+		}
+	}
+	if volume > 0 {
+		stats.VWAP = notional / volume
+	}
+	return stats
+}
+
+// Function to get the signed volume of a trade, positive when the buyer was the aggressor
+func tradeDelta(t TradeEvent) float64 {
+	if t.BuyerMaker {
+		return -t.Volume.Float64()
+	}
+	return t.Volume.Float64()
+}
+
+// Function to get the median volume of the levels, zero when there are none
+func medianVolume(levels []OrderbookEntry) float64 {
+	if len(levels) == 0 {
+		return 0
+	}
+	volumes := make([]float64, len(levels))
+	for i, l := range levels {
+		volumes[i] = l.Volume.Float64()
+	}
+	sort.Float64s(volumes)
+	n := len(volumes)
+	if n%2 == 1 {
+		return volumes[n/2]
+	}
+	return (volumes[n/2-1] + volumes[n/2]) / 2
+}
+
+// Function to flag the "whale" levels, whose volume exceeds `multiple` times the median volume of the levels.
+// Nothing is flagged when `multiple` isn't positive.
+func whaleLevels(levels []OrderbookEntry, multiple float64) []bool {
+	whales := make([]bool, len(levels))
+	if multiple <= 0 {
+		return whales
+	}
+	threshold := multiple * medianVolume(levels)
+	for i, l := range levels {
+		whales[i] = l.Volume.Float64() > threshold
+	}
+	return whales
+}
+
+// Function to get the change of the open interest and of the mark price of every sample, in percent of the
+// first sample. Samples taken before the first mark price arrived show no change of the mark price.
+func openInterestChanges(samples []openInterestSample) (oi, mark []float64) {
+	oi = make([]float64, len(samples))
+	mark = make([]float64, len(samples))
+	if len(samples) == 0 || samples[0].OpenInterest <= 0 {
+		return oi, mark
+	}
+	firstMark := 0.0
+	for i, s := range samples {
+		oi[i] = (s.OpenInterest/samples[0].OpenInterest - 1) * 100
+		if firstMark == 0 {
+			firstMark = s.MarkPrice
+		}
+		if firstMark > 0 && s.MarkPrice > 0 {
+			mark[i] = (s.MarkPrice/firstMark - 1) * 100
+		}
+	}
+	return oi, mark
+}
+
+// Function to parse a comma separated list of windows, e.g. "1m,5m,15m", sorted shortest first
+func parseWindows(s string) ([]time.Duration, error) {
+	var windows []time.Duration
+	for _, w := range strings.Split(s, ",") {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		d, err := time.ParseDuration(w)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", w, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid window %q: must be positive", w)
+		}
+		windows = append(windows, d)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+	return windows, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Function to build a trade at `at` seconds after the Unix epoch
+func trade(t *testing.T, price, volume string, buyerMaker bool, at int64) TradeEvent {
+	return TradeEvent{Symbol: "btcusdt", Price: dec(t, price), Volume: dec(t, volume), BuyerMaker: buyerMaker, Time: time.Unix(at, 0)}
+}
+
+// Function to build levels with the given volumes
+func volumeLevels(t *testing.T, volumes ...string) []OrderbookEntry {
+	entries := make([]OrderbookEntry, len(volumes))
+	for i, v := range volumes {
+		entries[i] = OrderbookEntry{Price: NewDecimal(int64(27000+i) * 100000000), Volume: dec(t, v)}
+	}
+	return entries
+}
+
+func TestComputeFlow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	for _, c := range []struct {
+		name   string
+		trades []TradeEvent
+		window time.Duration
+		want   flowStats
+	}{
+		{name: "no trades", window: time.Minute, want: flowStats{Window: time.Minute}},
+		{
+			name: "buys and sells",
+			trades: []TradeEvent{
+				trade(t, "100", "1", false, 970),
+				trade(t, "110", "3", true, 990),
+			},
+			window: time.Minute,
+			want:   flowStats{Window: time.Minute, VWAP: 107.5, BuyVolume: 1, SellVolume: 3},
+		},
+		{
+			// a trade right on the cutoff is still within the window, the one before it is not
+			name: "window cutoff",
+			trades: []TradeEvent{
+				trade(t, "1000", "5", false, 939),
+				trade(t, "100", "2", false, 940),
+				trade(t, "200", "2", true, 1000),
+			},
+			window: time.Minute,
+			want:   flowStats{Window: time.Minute, VWAP: 150, BuyVolume: 2, SellVolume: 2},
+		},
+		{
+			name:   "every trade too old",
+			trades: []TradeEvent{trade(t, "100", "1", false, 900)},
+			window: time.Minute,
+			want:   flowStats{Window: time.Minute},
+		},
+	} {
+		if got := computeFlow(c.trades, now, c.window); got != c.want {
+			t.Errorf("%s: computeFlow = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+
+	if got := (flowStats{BuyVolume: 1, SellVolume: 3}).Delta(); got != -2 {
+		t.Errorf("Delta = %g, want -2", got)
+	}
+}
+
+func TestMedianVolume(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		volumes []string
+		want    float64
+	}{
+		{"no levels", nil, 0},
+		{"single level", []string{"7"}, 7},
+		{"odd length", []string{"3", "1", "2"}, 2},
+		{"even length", []string{"4", "1", "3", "2"}, 2.5},
+	} {
+		if got := medianVolume(volumeLevels(t, c.volumes...)); got != c.want {
+			t.Errorf("%s: medianVolume = %g, want %g", c.name, got, c.want)
+		}
+	}
+}
+
+func TestWhaleLevels(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		volumes  []string
+		multiple float64
+		want     []bool
+	}{
+		{"no levels", nil, 3, []bool{}},
+		{"above the threshold", []string{"1", "1", "1", "10"}, 3, []bool{false, false, false, true}},
+		{"on the threshold", []string{"1", "3", "1"}, 3, []bool{false, false, false}},
+		{"multiple of zero", []string{"1", "1", "10"}, 0, []bool{false, false, false}},
+		{"negative multiple", []string{"1", "1", "10"}, -1, []bool{false, false, false}},
+	} {
+		if got := whaleLevels(volumeLevels(t, c.volumes...), c.multiple); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: whaleLevels = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default endpoints of the Binance futures API
+const (
+	binanceWSEndpoint   = "wss://fstream.binance.com/stream"
+	binanceRESTEndpoint = "https://fapi.binance.com"
+)
+
+// Default endpoints of the Binance spot API
+const (
+	binanceSpotWSEndpoint   = "wss://stream.binance.com:9443/stream"
+	binanceSpotRESTEndpoint = "https://api.binance.com"
+)
+
+// Funding interval of the Binance perpetuals
+const binanceFundingInterval = 8 * time.Hour
+
+// Structure representing any message of the combined stream.
+// The `data` field is decoded once `stream` tells which kind of update it is, acks and error frames
+// of the stream itself carry no `stream` field.
+type binanceMessage struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+	Error  *struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"error"`
+}
+
+// Structure representing the trade result from Binance.
+// The spot trades carry an always true `M` next to `m`, it is declared so that it isn't matched to `m`
+// for the same reason as in `BinanceMarkPriceResult`.
+type BinanceTradeResult struct {
+	Data struct {
+		Price      string `json:"p"`
+		Quantity   string `json:"q"`
+		BuyerMaker bool   `json:"m"`
+		BestMatch  bool   `json:"M"`
+		TradeTime  int64  `json:"T"`
+	} `json:"data"`
+}
+
+// Structure representing the mark price result from Binance
+// Every field of the message is declared, because encoding/json would otherwise
+// match e.g. `P` to the `p` field, as keys are matched case insensitively.
+type BinanceMarkPriceResult struct {
+	Data struct {
+		MarkPrice            string `json:"p"`
+		IndexPrice           string `json:"i"`
+		EstimatedSettlePrice string `json:"P"`
+		FundingRate          string `json:"r"`
+		NextFundingTime      int64  `json:"T"`
+	} `json:"data"`
+}
+
+// Structure representing the liquidation order result from Binance.
+// The average price and the accumulated filled quantity are used, as the order may fill at several prices.
+// Every field of the order is declared for the same reason as in `BinanceMarkPriceResult`, `s` would match `S`.
+type BinanceForceOrderResult struct {
+	Data struct {
+		Order struct {
+			Symbol         string `json:"s"`
+			Side           string `json:"S"`
+			OrderType      string `json:"o"`
+			TimeInForce    string `json:"f"`
+			Quantity       string `json:"q"`
+			Price          string `json:"p"`
+			AveragePrice   string `json:"ap"`
+			Status         string `json:"X"`
+			LastFilled     string `json:"l"`
+			FilledAccumQty string `json:"z"`
+			TradeTime      int64  `json:"T"`
+		} `json:"o"`
+	} `json:"data"`
+}
+
+// Structure representing the open interest from the Binance futures REST API
+type BinanceOpenInterest struct {
+	OpenInterest string `json:"openInterest"`
+	Time         int64  `json:"time"`
+}
+
+// Structure representing the depth result from Binance API
+type BinanceDepthResult struct {
+	FirstUpdateID     int64      `json:"U"`
+	FinalUpdateID     int64      `json:"u"`
+	PrevFinalUpdateID int64      `json:"pu"`
+	Asks              [][]string `json:"a"`
+	Bids              [][]string `json:"b"`
+}
+
+// Structure representing the depth response from Binance
+type BinanceDepthResponse struct {
+	Stream string             `json:"stream"`
+	Data   BinanceDepthResult `json:"data"`
+}
+
+// Structure representing the depth snapshot from the Binance REST API
+type BinanceDepthSnapshot struct {
+	LastUpdateID int64      `json:"lastUpdateId"`
+	Bids         [][]string `json:"bids"`
+	Asks         [][]string `json:"asks"`
+}
+
+// Structure representing the exchange info from the Binance REST API, only the price filter is decoded
+type BinanceExchangeInfo struct {
+	Symbols []struct {
+		Symbol  string `json:"symbol"`
+		Filters []struct {
+			FilterType string `json:"filterType"`
+			TickSize   string `json:"tickSize"`
+		} `json:"filters"`
+	} `json:"symbols"`
+}
+
+// Structure representing the Binance futures adapter, or the spot one when `spot` is set.
+// The combined stream is subscribed through its URL and the orderbooks are seeded over REST.
+// Both markets share the format of their messages, except that spot has no mark price stream
+// and its depth diffs carry no `pu`, as the spot update IDs follow each other without gaps.
+type binance struct {
+	wsendpoint   string
+	restendpoint string
+	client       *http.Client
+	spot         bool
+}
+
+// `newBinance()` is a constructor function for creating a new instance of the Binance adapter,
+// empty endpoints fall back to the production API
+func newBinance(wsendpoint, restendpoint string, client *http.Client) Exchange {
+	if wsendpoint == "" {
+		wsendpoint = binanceWSEndpoint
+	}
+	if restendpoint == "" {
+		restendpoint = binanceRESTEndpoint
+	}
+	return &binance{
+		wsendpoint:   wsendpoint,
+		restendpoint: restendpoint,
+		client:       client,
+	}
+}
+
+// `newBinanceSpot()` is a constructor function for creating a new instance of the Binance spot adapter,
+// empty endpoints fall back to the production API
+func newBinanceSpot(wsendpoint, restendpoint string, client *http.Client) Exchange {
+	if wsendpoint == "" {
+		wsendpoint = binanceSpotWSEndpoint
+	}
+	if restendpoint == "" {
+		restendpoint = binanceSpotRESTEndpoint
+	}
+	return &binance{
+		wsendpoint:   wsendpoint,
+		restendpoint: restendpoint,
+		client:       client,
+		spot:         true,
+	}
+}
+
+func (b *binance) Name() string {
+	if b.spot {
+		return "Binance"
+	}
+	return "Binancef"
+}
+
+// Function to build the combined stream URL subscribing to the mark price, depth, trade
+// and liquidation streams of every symbol
+func (b *binance) StreamURL(symbols []string) string {
+	streams := make([]string, 0, 4*len(symbols))
+	for _, s := range symbols {
+		if b.spot {
+			streams = append(streams, s+"@depth@100ms", s+"@aggTrade")
+		} else {
+			streams = append(streams, s+"@markPrice", s+"@depth", s+"@aggTrade", s+"@forceOrder")
+		}
+	}
+	return b.wsendpoint + "?streams=" + strings.Join(streams, "/")
+}
+
+// Function to get the path of a REST endpoint, e.g. `depth` is /fapi/v1/depth for futures and /api/v3/depth for spot
+func (b *binance) path(endpoint string) string {
+	if b.spot {
+		return "/api/v3/" + endpoint
+	}
+	return "/fapi/v1/" + endpoint
+}
+
+// The streams are part of the URL, so nothing has to be sent after connecting
+func (b *binance) Subscribe(symbols []string) []interface{} { return nil }
+
+// Function to decode a message of the combined stream.
+// The message is routed by its `stream` field, e.g. `btcusdt@depth`, into the typed result of that stream.
+// The part before `@` is the symbol and the part after it determines the kind of update.
+func (b *binance) Decode(msg []byte) ([]Event, error) {
+	var m binanceMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return nil, err
+	}
+	if m.Error != nil {
+		return nil, fmt.Errorf("error %d: %s", m.Error.Code, m.Error.Msg)
+	}
+	if m.Stream == "" {
+		// subscription acks and other frames without market data
+		return nil, nil
+	}
+	symbol, stream, _ := strings.Cut(m.Stream, "@")
+
+	switch stream {
+	case "depth", "depth@100ms":
+		resp := BinanceDepthResponse{Stream: m.Stream}
+		if err := json.Unmarshal(m.Data, &resp.Data); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Stream, err)
+		}
+		asks, err := parseBinanceLevels(resp.Data.Asks)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Stream, err)
+		}
+		bids, err := parseBinanceLevels(resp.Data.Bids)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Stream, err)
+		}
+		prev := resp.Data.PrevFinalUpdateID
+		if b.spot {
+			prev = resp.Data.FirstUpdateID - 1
+		}
+		return []Event{&DepthEvent{
+			Symbol:            symbol,
+			FirstUpdateID:     resp.Data.FirstUpdateID,
+			FinalUpdateID:     resp.Data.FinalUpdateID,
+			PrevFinalUpdateID: prev,
+			Asks:              asks,
+			Bids:              bids,
+		}}, nil
+	case "markPrice":
+		var res BinanceMarkPriceResult
+		if err := json.Unmarshal(m.Data, &res.Data); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Stream, err)
+		}
+		price, err := strconv.ParseFloat(res.Data.MarkPrice, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: mark price: %w", m.Stream, err)
+		}
+		index, err := strconv.ParseFloat(res.Data.IndexPrice, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: index price: %w", m.Stream, err)
+		}
+		ev := &MarkPriceEvent{
+			Symbol:          symbol,
+			MarkPrice:       price,
+			IndexPrice:      index,
+			FundingRate:     res.Data.FundingRate,
+			FundingInterval: binanceFundingInterval,
+		}
+		if res.Data.NextFundingTime > 0 {
+			ev.NextFundingTime = time.UnixMilli(res.Data.NextFundingTime)
+		}
+		return []Event{ev}, nil
+	case "aggTrade":
+		var res BinanceTradeResult
+		if err := json.Unmarshal(m.Data, &res.Data); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Stream, err)
+		}
+		price, err := ParseDecimal(res.Data.Price)
+		if err != nil {
+			return nil, fmt.Errorf("%s: price: %w", m.Stream, err)
+		}
+		volume, err := ParseDecimal(res.Data.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("%s: quantity: %w", m.Stream, err)
+		}
+		return []Event{&TradeEvent{
+			Symbol:     symbol,
+			Price:      price,
+			Volume:     volume,
+			BuyerMaker: res.Data.BuyerMaker,
+			Time:       time.UnixMilli(res.Data.TradeTime),
+		}}, nil
+	case "forceOrder":
+		var res BinanceForceOrderResult
+		if err := json.Unmarshal(m.Data, &res.Data); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Stream, err)
+		}
+		o := res.Data.Order
+		price, err := ParseDecimal(o.AveragePrice)
+		if err != nil {
+			return nil, fmt.Errorf("%s: average price: %w", m.Stream, err)
+		}
+		volume, err := ParseDecimal(o.FilledAccumQty)
+		if err != nil {
+			return nil, fmt.Errorf("%s: filled quantity: %w", m.Stream, err)
+		}
+		return []Event{&LiquidationEvent{
+			Symbol: symbol,
+			Side:   strings.ToLower(o.Side),
+			Price:  price,
+			Volume: volume,
+			Time:   time.UnixMilli(o.TradeTime),
+		}}, nil
+	}
+	return nil, nil
+}
+
+// Function to fetch the open interest of the given symbol, only the futures market has one
+func (b *binance) FetchOpenInterest(symbol string) (*OpenInterestEvent, error) {
+	if b.spot {
+		return nil, fmt.Errorf("open interest for %s: spot markets have no open interest", symbol)
+	}
+	query := url.Values{}
+	query.Set("symbol", strings.ToUpper(symbol))
+
+	resp, err := b.client.Get(b.restendpoint + b.path("openInterest") + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("open interest for %s: unexpected status %s", symbol, resp.Status)
+	}
+	var res BinanceOpenInterest
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("open interest for %s: %w", symbol, err)
+	}
+	oi, err := strconv.ParseFloat(res.OpenInterest, 64)
+	if err != nil {
+		return nil, fmt.Errorf("open interest for %s: %w", symbol, err)
+	}
+	return &OpenInterestEvent{Symbol: symbol, OpenInterest: oi, Time: time.UnixMilli(res.Time)}, nil
+}
+
+// Function to fetch a depth snapshot for the given symbol from the REST endpoint
+func (b *binance) FetchSnapshot(symbol string) (*DepthEvent, error) {
+	query := url.Values{}
+	query.Set("symbol", strings.ToUpper(symbol))
+	query.Set("limit", "1000")
+
+	resp, err := b.client.Get(b.restendpoint + b.path("depth") + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("depth snapshot for %s: unexpected status %s", symbol, resp.Status)
+	}
+	var snap BinanceDepthSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("depth snapshot for %s: %w", symbol, err)
+	}
+	asks, err := parseBinanceLevels(snap.Asks)
+	if err != nil {
+		return nil, fmt.Errorf("depth snapshot for %s: %w", symbol, err)
+	}
+	bids, err := parseBinanceLevels(snap.Bids)
+	if err != nil {
+		return nil, fmt.Errorf("depth snapshot for %s: %w", symbol, err)
+	}
+	return &DepthEvent{
+		Symbol:        symbol,
+		Snapshot:      true,
+		FinalUpdateID: snap.LastUpdateID,
+		Asks:          asks,
+		Bids:          bids,
+	}, nil
+}
+
+// Function to fetch the tick sizes of the given symbols from the exchange info
+func (b *binance) TickSizes(symbols []string) (map[string]Decimal, error) {
+	resp, err := b.client.Get(b.restendpoint + b.path("exchangeInfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange info: unexpected status %s", resp.Status)
+	}
+	var info BinanceExchangeInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("exchange info: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		wanted[s] = true
+	}
+	ticks := make(map[string]Decimal, len(symbols))
+	for _, sym := range info.Symbols {
+		symbol := strings.ToLower(sym.Symbol)
+		if !wanted[symbol] {
+			continue
+		}
+		for _, f := range sym.Filters {
+			if f.FilterType != "PRICE_FILTER" {
+				continue
+			}
+			tick, err := ParseDecimal(f.TickSize)
+			if err != nil {
+				return nil, fmt.Errorf("exchange info: tick size of %s: %w", sym.Symbol, err)
+			}
+			ticks[symbol] = tick
+		}
+	}
+	return ticks, nil
+}
+
+// Function to parse the `[price, volume]` pairs of a depth update or snapshot
+func parseBinanceLevels(levels [][]string) ([]OrderbookEntry, error) {
+	entries := make([]OrderbookEntry, len(levels))
+	for i, level := range levels {
+		if len(level) != 2 {
+			return nil, fmt.Errorf("malformed level %q", level)
+		}
+		price, err := ParseDecimal(level[0])
+		if err != nil {
+			return nil, fmt.Errorf("level price: %w", err)
+		}
+		volume, err := ParseDecimal(level[1])
+		if err != nil {
+			return nil, fmt.Errorf("level volume: %w", err)
+		}
+		entries[i] = OrderbookEntry{Price: price, Volume: volume}
+	}
+	return entries, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Structure representing a recorded frame of a venue and the events it decodes into
+type decodeCase struct {
+	fixture string // file under testdata/<venue>
+	want    []Event
+	wantErr bool
+}
+
+// Function to decode every recorded frame with the adapter and compare the events with the expected ones
+func runDecodeCases(t *testing.T, ex Exchange, venue string, cases []decodeCase) {
+	for _, c := range cases {
+		t.Run(c.fixture, func(t *testing.T) {
+			msg, err := os.ReadFile(filepath.Join("testdata", venue, c.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ex.Decode(msg)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("Decode() error = %v, want error %t", err, c.wantErr)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Decode() =\n%s\nwant\n%s", formatEvents(got), formatEvents(c.want))
+			}
+		})
+	}
+}
+
+// Function to print the events with their fields, as a slice of them would only print their pointers
+func formatEvents(events []Event) string {
+	s := ""
+	for _, ev := range events {
+		s += fmt.Sprintf("%T %+v\n", ev, ev)
+	}
+	return s
+}
+
+// Function to parse a decimal which is known to be valid
+func dec(t *testing.T, s string) Decimal {
+	t.Helper()
+	d, err := ParseDecimal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestBinanceDecode(t *testing.T) {
+	runDecodeCases(t, newBinance("", "", nil), "binance", []decodeCase{
+		{fixture: "depth.json", want: []Event{&DepthEvent{
+			Symbol:            "btcusdt",
+			FirstUpdateID:     3900000001,
+			FinalUpdateID:     3900000005,
+			PrevFinalUpdateID: 3900000000,
+			Asks:              []OrderbookEntry{{Price: dec(t, "36500.2"), Volume: dec(t, "3.1")}},
+			Bids: []OrderbookEntry{
+				{Price: dec(t, "36500.1"), Volume: dec(t, "1.25")},
+				{Price: dec(t, "36499.9"), Volume: Decimal{}},
+			},
+		}}},
+		{fixture: "markprice.json", want: []Event{&MarkPriceEvent{
+			Symbol:          "btcusdt",
+			MarkPrice:       36500.12345678,
+			IndexPrice:      36490,
+			FundingRate:     "0.00010000",
+			FundingInterval: 8 * time.Hour,
+			NextFundingTime: time.UnixMilli(1700006400000),
+		}}},
+		{fixture: "aggtrade.json", want: []Event{&TradeEvent{
+			Symbol:     "btcusdt",
+			Price:      dec(t, "36500.1"),
+			Volume:     dec(t, "0.015"),
+			BuyerMaker: true,
+			Time:       time.UnixMilli(1700000000099),
+		}}},
+		{fixture: "forceorder.json", want: []Event{&LiquidationEvent{
+			Symbol: "btcusdt",
+			Side:   "sell",
+			Price:  dec(t, "36410.5"),
+			Volume: dec(t, "0.014"),
+			Time:   time.UnixMilli(1700000000190),
+		}}},
+		{fixture: "ack.json"},
+		{fixture: "error.json", wantErr: true},
+		{fixture: "malformed.json", wantErr: true},
+	})
+}
+
+func TestBinanceSpotDecode(t *testing.T) {
+	runDecodeCases(t, newBinanceSpot("", "", nil), "binance", []decodeCase{
+		// the spot diffs carry no `pu`, the update IDs follow each other without gaps
+		{fixture: "depth_spot.json", want: []Event{&DepthEvent{
+			Symbol:            "btcusdt",
+			FirstUpdateID:     157,
+			FinalUpdateID:     160,
+			PrevFinalUpdateID: 156,
+			Asks:              []OrderbookEntry{{Price: dec(t, "36500.11"), Volume: Decimal{}}},
+			Bids:              []OrderbookEntry{{Price: dec(t, "36500.1"), Volume: dec(t, "0.025")}},
+		}}},
+		// the spot trades carry an always true `M` next to `m`, the buyer was the aggressor of this one
+		{fixture: "aggtrade_spot.json", want: []Event{&TradeEvent{
+			Symbol: "btcusdt",
+			Price:  dec(t, "36500.12"),
+			Volume: dec(t, "0.003"),
+			Time:   time.UnixMilli(1700000000099),
+		}}},
+	})
+}
+
+// Function to decode a frame of the combined stream into a generic map, the way the adapter did before
+// the messages were decoded into typed results. It is only kept to benchmark the typed decoding against.
+func decodeBinanceMap(msg []byte) ([]Event, error) {
+	var result map[string]interface{}
+	if err := json.Unmarshal(msg, &result); err != nil {
+		return nil, err
+	}
+	symbol, stream, _ := strings.Cut(result["stream"].(string), "@")
+	data := result["data"].(map[string]interface{})
+	levels := func(levels []interface{}) []OrderbookEntry {
+		entries := make([]OrderbookEntry, len(levels))
+		for i, v := range levels {
+			level := v.([]interface{})
+			entries[i].Price, _ = ParseDecimal(level[0].(string))
+			entries[i].Volume, _ = ParseDecimal(level[1].(string))
+		}
+		return entries
+	}
+
+	switch stream {
+	case "depth":
+		return []Event{&DepthEvent{
+			Symbol:            symbol,
+			FirstUpdateID:     int64(data["U"].(float64)),
+			FinalUpdateID:     int64(data["u"].(float64)),
+			PrevFinalUpdateID: int64(data["pu"].(float64)),
+			Asks:              levels(data["a"].([]interface{})),
+			Bids:              levels(data["b"].([]interface{})),
+		}}, nil
+	case "markPrice":
+		price, _ := strconv.ParseFloat(data["p"].(string), 64)
+		index, _ := strconv.ParseFloat(data["i"].(string), 64)
+		return []Event{&MarkPriceEvent{
+			Symbol:          symbol,
+			MarkPrice:       price,
+			IndexPrice:      index,
+			FundingRate:     data["r"].(string),
+			FundingInterval: binanceFundingInterval,
+			NextFundingTime: time.UnixMilli(int64(data["T"].(float64))),
+		}}, nil
+	case "aggTrade":
+		price, _ := ParseDecimal(data["p"].(string))
+		volume, _ := ParseDecimal(data["q"].(string))
+		return []Event{&TradeEvent{
+			Symbol:     symbol,
+			Price:      price,
+			Volume:     volume,
+			BuyerMaker: data["m"].(bool),
+			Time:       time.UnixMilli(int64(data["T"].(float64))),
+		}}, nil
+	}
+	return nil, nil
+}
+
+// Function to get the frames the decoding is benchmarked with, the recorded ones and a depth diff of 50 levels a side
+func benchmarkFrames(b *testing.B) (names []string, frames [][]byte) {
+	for _, name := range []string{"depth", "markprice", "aggtrade"} {
+		msg, err := os.ReadFile(filepath.Join("testdata", "binance", name+".json"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		names, frames = append(names, name), append(frames, msg)
+	}
+	var asks, bids []string
+	for i := 0; i < 50; i++ {
+		asks = append(asks, fmt.Sprintf(`["%d.%02d","%d.%03d"]`, 36500+i, i, i%7, i))
+		bids = append(bids, fmt.Sprintf(`["%d.%02d","%d.%03d"]`, 36499-i, i, i%5, i))
+	}
+	names = append(names, "depth50")
+	frames = append(frames, []byte(fmt.Sprintf(`{"stream":"btcusdt@depth","data":{"e":"depthUpdate","E":1700000000123,"T":1700000000120,"s":"BTCUSDT","U":3900000001,"u":3900000005,"pu":3900000000,"b":[%s],"a":[%s]}}`,
+		strings.Join(bids, ","), strings.Join(asks, ","))))
+	return names, frames
+}
+
+// Function to benchmark a decoder on every frame of `benchmarkFrames`
+func benchmarkDecode(b *testing.B, decode func(msg []byte) ([]Event, error)) {
+	names, frames := benchmarkFrames(b)
+	for i, msg := range frames {
+		b.Run(names[i], func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := decode(msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecodeMap(b *testing.B) {
+	benchmarkDecode(b, decodeBinanceMap)
+}
+
+func BenchmarkDecodeTyped(b *testing.B) {
+	benchmarkDecode(b, newBinance("", "", nil).Decode)
+}
@@ -0,0 +1,95 @@
+package main
+
+import "time"
+
+// Number of one minute candles kept per market, enough for 60 candles of the longest interval
+const maxCandles = 15 * 60
+
+// Intervals the chart can be switched between, the first one is shown at startup
+var candleIntervals = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// Structure representing an OHLC candle with the traded volume
+type Candle struct {
+	Start  time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Function to add a trade to the candle
+func (c *Candle) add(price, volume float64) {
+	if price > c.High {
+		c.High = price
+	}
+	if price < c.Low {
+		c.Low = price
+	}
+	c.Close = price
+	c.Volume += volume
+}
+
+// Structure building one minute candles from the trades of a market.
+// Longer intervals are aggregated from the one minute candles when they are displayed.
+type candleBuilder struct {
+	candles []Candle // oldest first
+}
+
+// Function to add a trade to the candle of its minute, starting a new candle when needed.
+// Late trades go into the kept candle of their minute, the ones whose minute has no candle are dropped,
+// including those older than every kept candle.
+func (b *candleBuilder) add(t TradeEvent) {
+	var (
+		start  = t.Time.Truncate(time.Minute)
+		price  = t.Price.Float64()
+		volume = t.Volume.Float64()
+	)
+	for i := len(b.candles) - 1; i >= 0; i-- {
+		c := &b.candles[i]
+		if c.Start.Equal(start) {
+			c.add(price, volume)
+			return
+		}
+		if c.Start.Before(start) {
+			break
+		}
+	}
+	if n := len(b.candles); n > 0 && !b.candles[n-1].Start.Before(start) {
+		return
+	}
+	if len(b.candles) == maxCandles {
+		b.candles = append(b.candles[:0], b.candles[1:]...)
+	}
+	b.candles = append(b.candles, Candle{Start: start, Open: price, High: price, Low: price, Close: price, Volume: volume})
+}
+
+// Function to copy the candles out of the builder, oldest first
+func (b *candleBuilder) snapshot() []Candle {
+	candles := make([]Candle, len(b.candles))
+	copy(candles, b.candles)
+	return candles
+}
+
+// Function to aggregate one minute candles into candles of the given interval
+func aggregateCandles(minutes []Candle, interval time.Duration) []Candle {
+	var out []Candle
+	for _, c := range minutes {
+		start := c.Start.Truncate(interval)
+		if n := len(out); n > 0 && out[n-1].Start.Equal(start) {
+			last := &out[n-1]
+			if c.High > last.High {
+				last.High = c.High
+			}
+			if c.Low < last.Low {
+				last.Low = c.Low
+			}
+			last.Close = c.Close
+			last.Volume += c.Volume
+			continue
+		}
+		c.Start = start
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Function to get the time `minutes` and `seconds` after the start of the candles of the tests
+func candleTime(minutes, seconds int) time.Time {
+	return time.Date(2023, 11, 14, 22, 0, 0, 0, time.UTC).Add(time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second)
+}
+
+// Function to build a trade at the given time after the start of the candles of the tests
+func candleTrade(t *testing.T, price, volume string, minutes, seconds int) TradeEvent {
+	tr := trade(t, price, volume, false, 0)
+	tr.Time = candleTime(minutes, seconds)
+	return tr
+}
+
+func TestCandleBuilderAdd(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		trades []TradeEvent
+		want   []Candle
+	}{
+		{
+			name: "one minute",
+			trades: []TradeEvent{
+				candleTrade(t, "100", "1", 0, 1),
+				candleTrade(t, "103", "0.5", 0, 20),
+				candleTrade(t, "98", "2", 0, 40),
+				candleTrade(t, "101", "1", 0, 59),
+			},
+			want: []Candle{{Start: candleTime(0, 0), Open: 100, High: 103, Low: 98, Close: 101, Volume: 4.5}},
+		},
+		{
+			name:   "new minute",
+			trades: []TradeEvent{candleTrade(t, "100", "1", 0, 30), candleTrade(t, "102", "2", 1, 0)},
+			want: []Candle{
+				{Start: candleTime(0, 0), Open: 100, High: 100, Low: 100, Close: 100, Volume: 1},
+				{Start: candleTime(1, 0), Open: 102, High: 102, Low: 102, Close: 102, Volume: 2},
+			},
+		},
+		{
+			// a trade arriving after the next minute started still goes into the candle of its own minute
+			name:   "late trade",
+			trades: []TradeEvent{candleTrade(t, "100", "1", 0, 10), candleTrade(t, "102", "2", 1, 5), candleTrade(t, "95", "3", 0, 50)},
+			want: []Candle{
+				{Start: candleTime(0, 0), Open: 100, High: 100, Low: 95, Close: 95, Volume: 4},
+				{Start: candleTime(1, 0), Open: 102, High: 102, Low: 102, Close: 102, Volume: 2},
+			},
+		},
+		{
+			name:   "older than every candle",
+			trades: []TradeEvent{candleTrade(t, "100", "1", 1, 0), candleTrade(t, "90", "5", 0, 59)},
+			want:   []Candle{{Start: candleTime(1, 0), Open: 100, High: 100, Low: 100, Close: 100, Volume: 1}},
+		},
+		{
+			// a candle isn't inserted before a later one, so a late trade of a minute without a candle is dropped
+			name:   "late trade of a gap",
+			trades: []TradeEvent{candleTrade(t, "100", "1", 0, 10), candleTrade(t, "102", "2", 2, 5), candleTrade(t, "90", "5", 1, 30)},
+			want: []Candle{
+				{Start: candleTime(0, 0), Open: 100, High: 100, Low: 100, Close: 100, Volume: 1},
+				{Start: candleTime(2, 0), Open: 102, High: 102, Low: 102, Close: 102, Volume: 2},
+			},
+		},
+	} {
+		var b candleBuilder
+		for _, tr := range c.trades {
+			b.add(tr)
+		}
+		if got := b.snapshot(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: candles = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+// Once `maxCandles` minutes are kept, the oldest candle makes room for the new one
+func TestCandleBuilderDropsOldest(t *testing.T) {
+	var b candleBuilder
+	for i := 0; i <= maxCandles; i++ {
+		b.add(candleTrade(t, "100", "1", i, 0))
+	}
+	candles := b.snapshot()
+	if len(candles) != maxCandles || !candles[0].Start.Equal(candleTime(1, 0)) || !candles[maxCandles-1].Start.Equal(candleTime(maxCandles, 0)) {
+		t.Fatalf("kept %d candles from %s to %s", len(candles), candles[0].Start, candles[len(candles)-1].Start)
+	}
+	// the dropped minute is older than every kept candle now
+	b.add(candleTrade(t, "50", "1", 0, 30))
+	if got := b.snapshot(); !reflect.DeepEqual(got, candles) {
+		t.Error("a trade of the dropped minute changed the candles")
+	}
+}
+
+func TestAggregateCandles(t *testing.T) {
+	// minutes 22:03 to 22:16 with gaps, the first 5 minute candle and the second 15 minute one are partial
+	var minutes []Candle
+	for _, m := range []int{3, 4, 5, 6, 9, 10, 14, 15, 16} {
+		open := 100 + float64(m)
+		minutes = append(minutes, Candle{Start: candleTime(m, 0), Open: open, High: open + float64(m%4), Low: open - float64(m%3), Close: open + 0.5, Volume: float64(m)})
+	}
+	for _, c := range []struct {
+		interval time.Duration
+		want     []Candle
+	}{
+		{time.Minute, minutes},
+		{5 * time.Minute, []Candle{
+			// 22:00 to 22:04 only has two minutes, the candle starts at the interval anyway
+			{Start: candleTime(0, 0), Open: 103, High: 106, Low: 103, Close: 104.5, Volume: 7},
+			{Start: candleTime(5, 0), Open: 105, High: 110, Low: 103, Close: 109.5, Volume: 20},
+			{Start: candleTime(10, 0), Open: 110, High: 116, Low: 109, Close: 114.5, Volume: 24},
+			{Start: candleTime(15, 0), Open: 115, High: 118, Low: 115, Close: 116.5, Volume: 31},
+		}},
+		{15 * time.Minute, []Candle{
+			{Start: candleTime(0, 0), Open: 103, High: 116, Low: 103, Close: 114.5, Volume: 51},
+			{Start: candleTime(15, 0), Open: 115, High: 118, Low: 115, Close: 116.5, Volume: 31},
+		}},
+	} {
+		if got := aggregateCandles(minutes, c.interval); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s candles =\n%+v\nwant\n%+v", c.interval, got, c.want)
+		}
+	}
+	if got := aggregateCandles(nil, 5*time.Minute); len(got) != 0 {
+		t.Errorf("candles of no minutes = %+v", got)
+	}
+}
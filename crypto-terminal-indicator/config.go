@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Structure representing a configuration file, e.g.
+//
+//	exchange: binance
+//	symbols: [btcusdt, ethusdt]
+//	depth: 20
+//	refresh: 250ms
+//	theme: colorblind
+//	colors:
+//	  accent: fg:magenta
+//	hide: [flow, venues]
+//	layout:
+//	  book_width: 52
+//
+// Every setting but the colors and the layout has a command line flag of the same name, with dashes for underscores,
+// a flag given on the command line wins over the file. Settings left out keep the default of their flag.
+type config struct {
+	Exchange string          `yaml:"exchange"`
+	Symbols  []string        `yaml:"symbols"`
+	Depth    int             `yaml:"depth"`
+	Refresh  time.Duration   `yaml:"refresh"`
+	Stale    time.Duration   `yaml:"stale"`
+	Windows  []time.Duration `yaml:"windows"`
+	Whale    *float64        `yaml:"whale"` // a pointer as 0 disables the highlighting
+	Alerts   string          `yaml:"alerts"`
+	Compare  []string        `yaml:"compare"`
+	Theme    string          `yaml:"theme"`
+	Hide     []string        `yaml:"hide"`
+
+	LargeLiquidation *float64      `yaml:"large_liquidation"` // a pointer as 0 disables the highlighting
+	OIInterval       time.Duration `yaml:"oi_interval"`
+
+	Colors Theme        `yaml:"colors"` // overrides of single styles of the theme
+	Layout layoutConfig `yaml:"layout"`
+}
+
+// Structure representing the sizes the widgets are laid out with. A size left out or zero keeps its default,
+// except for the margin, which can be set to zero and only keeps its default when it is left out.
+type layoutConfig struct {
+	Margin       *int `yaml:"margin"`        // columns between two panels, a pointer as 0 puts the panels side by side
+	HeaderHeight int  `yaml:"header_height"` // height of the header panels
+	BookWidth    int  `yaml:"book_width"`    // width of the orderbook table
+	TapeWidth    int  `yaml:"tape_width"`    // width of the time & sales and order flow panels
+	VolumeHeight int  `yaml:"volume_height"` // maximum height of the volume bars below the chart
+
+	LiquidationWidth int `yaml:"liquidation_width"` // width of the liquidations and open interest panels
+}
+
+// Panels which can be hidden with `--hide`, the ticker, the market price, the feed and the orderbook always show
+var hideablePanels = []string{"index", "funding", "book", "trades", "flow", "chart", "venues", "liquidations", "oi"}
+
+// Function to load a configuration file. JSON is a subset of YAML, so the file can be written in JSON as well.
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Function to set the flags from the configuration, flags given on the command line are left as they are
+func (c *config) apply(fs *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	values := map[string]string{
+		"exchange": c.Exchange,
+		"symbols":  strings.Join(c.Symbols, ","),
+		"alerts":   c.Alerts,
+		"compare":  strings.Join(c.Compare, ","),
+		"theme":    c.Theme,
+		"hide":     strings.Join(c.Hide, ","),
+	}
+	if c.Depth != 0 {
+		values["depth"] = strconv.Itoa(c.Depth)
+	}
+	if c.Refresh != 0 {
+		values["refresh"] = c.Refresh.String()
+	}
+	if c.Stale != 0 {
+		values["stale"] = c.Stale.String()
+	}
+	if len(c.Windows) > 0 {
+		windows := make([]string, len(c.Windows))
+		for i, w := range c.Windows {
+			windows[i] = w.String()
+		}
+		values["windows"] = strings.Join(windows, ",")
+	}
+	if c.Whale != nil {
+		values["whale"] = strconv.FormatFloat(*c.Whale, 'g', -1, 64)
+	}
+	if c.LargeLiquidation != nil {
+		values["large-liquidation"] = strconv.FormatFloat(*c.LargeLiquidation, 'g', -1, 64)
+	}
+	if c.OIInterval != 0 {
+		values["oi-interval"] = c.OIInterval.String()
+	}
+
+	for name, value := range values {
+		if value == "" || explicit[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("config: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Function to fill the sizes left at zero, and the margin when it is left out or negative, with their defaults
+func (l layoutConfig) withDefaults() layoutConfig {
+	if l.Margin == nil || *l.Margin < 0 {
+		margin := 2
+		l.Margin = &margin
+	}
+	for _, s := range []struct {
+		size *int
+		def  int
+	}{
+		{&l.HeaderHeight, 3},
+		{&l.BookWidth, 46},
+		{&l.TapeWidth, 40},
+		{&l.VolumeHeight, 6},
+		{&l.LiquidationWidth, 36},
+	} {
+		if *s.size <= 0 {
+			*s.size = s.def
+		}
+	}
+	return l
+}
+
+// Function to parse the comma separated list of the panels to hide
+func parseHidden(s string) (map[string]bool, error) {
+	hidden := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, p := range hideablePanels {
+			if p == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown panel %q, expected one of %s", name, strings.Join(hideablePanels, ", "))
+		}
+		hidden[name] = true
+	}
+	return hidden, nil
+}
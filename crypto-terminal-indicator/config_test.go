@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function to define the flags of the settings a configuration file holds, with the defaults of the command line
+func configFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("crypto-terminal-indicator", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.String("exchange", "binance", "")
+	fs.String("symbols", "", "")
+	fs.Int("depth", 10, "")
+	fs.Duration("refresh", 100*time.Millisecond, "")
+	fs.Duration("stale", 5*time.Second, "")
+	fs.String("windows", "1m,5m,15m", "")
+	fs.Float64("whale", 5, "")
+	fs.String("alerts", "", "")
+	fs.String("compare", "", "")
+	fs.String("theme", "default", "")
+	fs.String("hide", "", "")
+	fs.Float64("large-liquidation", 100000, "")
+	fs.Duration("oi-interval", 10*time.Second, "")
+	return fs
+}
+
+// Function to write a configuration file and load it
+func writeConfig(t *testing.T, content string) *config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestConfigApply(t *testing.T) {
+	cfg := writeConfig(t, `
+exchange: kraken
+symbols: [PI_XBTUSD, PI_ETHUSD]
+depth: 20
+refresh: 250ms
+windows: [1m, 1h]
+whale: 0
+theme: colorblind
+hide: [flow, venues]
+oi_interval: 30s
+`)
+	for _, c := range []struct {
+		name string
+		args []string
+		want map[string]string
+	}{
+		{
+			name: "file only",
+			want: map[string]string{
+				"exchange": "kraken", "symbols": "PI_XBTUSD,PI_ETHUSD", "depth": "20", "refresh": "250ms", "windows": "1m0s,1h0m0s",
+				"whale": "0", "theme": "colorblind", "hide": "flow,venues", "oi-interval": "30s",
+				// the settings left out of the file keep the defaults of their flags
+				"stale": "5s", "large-liquidation": "100000", "alerts": "",
+			},
+		},
+		{
+			// flags given on the command line win, even when they are set to their default
+			name: "command line wins",
+			args: []string{"--depth", "5", "--theme", "default", "--whale=3", "--hide", ""},
+			want: map[string]string{
+				"exchange": "kraken", "symbols": "PI_XBTUSD,PI_ETHUSD", "depth": "5", "refresh": "250ms",
+				"whale": "3", "theme": "default", "hide": "", "oi-interval": "30s",
+			},
+		},
+	} {
+		fs := configFlags()
+		if err := fs.Parse(c.args); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.apply(fs); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for name, want := range c.want {
+			if got := fs.Lookup(name).Value.String(); got != want {
+				t.Errorf("%s: --%s = %q, want %q", c.name, name, got, want)
+			}
+		}
+	}
+}
+
+func TestConfigApplyInvalid(t *testing.T) {
+	// a value which isn't valid for its flag is reported with the name of the setting
+	cfg := &config{Windows: []time.Duration{time.Minute}}
+	fs := flag.NewFlagSet("crypto-terminal-indicator", flag.ContinueOnError)
+	fs.Int("windows", 0, "")
+	if err := cfg.apply(fs); err == nil || !strings.Contains(err.Error(), "windows") {
+		t.Errorf("apply() error = %v, want one about windows", err)
+	}
+}
+
+func TestLayoutDefaults(t *testing.T) {
+	zero, two, four := 0, 2, 4
+	defaults := layoutConfig{Margin: &two, HeaderHeight: 3, BookWidth: 46, TapeWidth: 40, VolumeHeight: 6, LiquidationWidth: 36}
+	for _, c := range []struct {
+		name   string
+		layout string
+		want   layoutConfig
+	}{
+		{"empty", "layout: {}", defaults},
+		{"zero margin", "layout: {margin: 0}", layoutConfig{Margin: &zero, HeaderHeight: 3, BookWidth: 46, TapeWidth: 40, VolumeHeight: 6, LiquidationWidth: 36}},
+		{"sizes", "layout: {margin: 4, book_width: 52, volume_height: 10}", layoutConfig{Margin: &four, HeaderHeight: 3, BookWidth: 52, TapeWidth: 40, VolumeHeight: 10, LiquidationWidth: 36}},
+		// zero and negative sizes can't be laid out, they keep the defaults
+		{"zero and negative sizes", "layout: {margin: -1, header_height: 0, tape_width: -5}", defaults},
+	} {
+		got := writeConfig(t, c.layout).Layout.withDefaults()
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: layout = %+v with margin %d, want %+v with margin %d", c.name, got, *got.Margin, c.want, *c.want.Margin)
+		}
+	}
+}
+
+func TestLookupTheme(t *testing.T) {
+	colorblind := themes["colorblind"]
+	withAccent := colorblind
+	withAccent.Accent = "fg:magenta"
+	withAccent.Alert = "fg:white,bg:red"
+	for _, c := range []struct {
+		name    string
+		theme   string
+		colors  Theme
+		want    Theme
+		wantErr bool
+	}{
+		{name: "built in", theme: "colorblind", want: colorblind},
+		{name: "any case", theme: "ColorBlind", want: colorblind},
+		// only the styles set in the file override the theme, the others are kept
+		{name: "overrides", theme: "colorblind", colors: Theme{Accent: "fg:magenta", Alert: "fg:white,bg:red"}, want: withAccent},
+		{name: "unknown", theme: "solarized", wantErr: true},
+	} {
+		got, err := lookupTheme(c.theme, c.colors)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: lookupTheme() error = %v, want error %t", c.name, err, c.wantErr)
+			continue
+		}
+		if err == nil && got != c.want {
+			t.Errorf("%s: theme = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+	if _, err := lookupTheme("solarized", Theme{}); err == nil || !strings.Contains(err.Error(), "colorblind, default, monochrome") {
+		t.Errorf("lookupTheme() error = %v, want the known themes listed", err)
+	}
+	// the overrides are copied, the built in theme isn't changed
+	if themes["colorblind"] != colorblind || colorblind.Accent == "fg:magenta" {
+		t.Error("the overrides changed the built in theme")
+	}
+}
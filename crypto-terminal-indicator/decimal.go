@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Number of decimal places kept by a `Decimal`, which is enough for every price and quantity
+// published by the supported exchanges
+const decimalPlaces = 8
+
+// Powers of ten up to 10^decimalPlaces
+var pow10 = [decimalPlaces + 1]uint64{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000}
+
+// `Decimal` is a fixed-point number with `decimalPlaces` decimal places.
+// Prices and volumes are parsed into it exactly, so equal prices always map to the same
+// orderbook level, which isn't guaranteed when they go through float64.
+// It is a 128-bit two's complement count of 10^-decimalPlaces units, as 64 bits only reach about 9.2e10
+// with 8 decimal places, which the quantities of the levels of low priced coins exceed.
+// Decimals can be compared with `==`, they are ordered with `Cmp`.
+type Decimal struct {
+	hi uint64
+	lo uint64
+}
+
+// `NewDecimal()` is a constructor function for creating a `Decimal` of `units` 10^-decimalPlaces units,
+// e.g. NewDecimal(1000000) is 0.01
+func NewDecimal(units int64) Decimal {
+	return Decimal{hi: uint64(units >> 63), lo: uint64(units)}
+}
+
+// Function to parse a decimal string such as "27123.40", "-0.5" or "1.5e-05".
+// An error is returned for malformed input, for values which don't fit and for values
+// with more than `decimalPlaces` significant decimal places.
+func ParseDecimal(s string) (Decimal, error) {
+	str := s
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	exp := 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", str)
+		}
+		exp = e
+		s = s[:i]
+	}
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	digits := intPart + fracPart
+	if digits == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", str)
+	}
+
+	// `places` is the number of digits after the decimal point once the exponent is applied,
+	// trailing zeros beyond the precision carry no information and are dropped
+	places := len(fracPart) - exp
+	for places > decimalPlaces && len(digits) > 1 && digits[len(digits)-1] == '0' {
+		digits = digits[:len(digits)-1]
+		places--
+	}
+	if places > decimalPlaces {
+		return Decimal{}, fmt.Errorf("decimal %q has more than %d decimal places", str, decimalPlaces)
+	}
+
+	var (
+		v  Decimal
+		ok = true
+	)
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", str)
+		}
+		if v, ok = v.mulAdd(10, uint64(c-'0')); !ok {
+			return Decimal{}, fmt.Errorf("decimal %q out of range", str)
+		}
+	}
+	for ; places < decimalPlaces; places++ {
+		if v, ok = v.mulAdd(10, 0); !ok {
+			return Decimal{}, fmt.Errorf("decimal %q out of range", str)
+		}
+	}
+	// the top bit is the sign
+	if v.hi>>63 != 0 {
+		return Decimal{}, fmt.Errorf("decimal %q out of range", str)
+	}
+	if neg {
+		v = v.Neg()
+	}
+	return v, nil
+}
+
+// Function to check if the decimal is zero
+func (d Decimal) IsZero() bool { return d == Decimal{} }
+
+// Function to get the sign of the decimal, -1, 0 or +1
+func (d Decimal) Sign() int {
+	switch {
+	case int64(d.hi) < 0:
+		return -1
+	case d.IsZero():
+		return 0
+	}
+	return 1
+}
+
+// Function to compare two decimals, it returns -1 when `d` is less than `e`, 0 when they are equal and +1 otherwise
+func (d Decimal) Cmp(e Decimal) int {
+	switch {
+	case d.hi == e.hi && d.lo == e.lo:
+		return 0
+	case int64(d.hi) < int64(e.hi), d.hi == e.hi && d.lo < e.lo:
+		return -1
+	}
+	return 1
+}
+
+// Function to add two decimals
+func (d Decimal) Add(e Decimal) Decimal {
+	lo, carry := bits.Add64(d.lo, e.lo, 0)
+	hi, _ := bits.Add64(d.hi, e.hi, carry)
+	return Decimal{hi: hi, lo: lo}
+}
+
+// Function to subtract a decimal
+func (d Decimal) Sub(e Decimal) Decimal {
+	lo, borrow := bits.Sub64(d.lo, e.lo, 0)
+	hi, _ := bits.Sub64(d.hi, e.hi, borrow)
+	return Decimal{hi: hi, lo: lo}
+}
+
+// Function to negate the decimal
+func (d Decimal) Neg() Decimal {
+	return Decimal{}.Sub(d)
+}
+
+// Function to multiply the decimal by an integer, e.g. a tick size by a number of ticks
+func (d Decimal) Mul(n int64) Decimal {
+	neg := (d.Sign() < 0) != (n < 0)
+	m := uint64(n)
+	if n < 0 {
+		m = -m
+	}
+	v, _ := d.abs().mulAdd(m, 0)
+	if neg {
+		return v.Neg()
+	}
+	return v
+}
+
+// Function to get the remainder of dividing the decimal by `e`, it has the sign of `d` like Go's `%` operator
+func (d Decimal) Mod(e Decimal) Decimal {
+	r := d.abs().umod(e.abs())
+	if d.Sign() < 0 {
+		return r.Neg()
+	}
+	return r
+}
+
+// Function to convert the decimal to a float64, for display and statistics only
+func (d Decimal) Float64() float64 {
+	a := d.abs()
+	f := (float64(a.hi)*(1<<64) + float64(a.lo)) / float64(pow10[decimalPlaces])
+	if d.Sign() < 0 {
+		return -f
+	}
+	return f
+}
+
+// Function to get the number of decimal places needed to print the decimal exactly,
+// e.g. 2 for a tick size of 0.01
+func (d Decimal) Places() int {
+	_, frac := d.abs().udivmod(pow10[decimalPlaces])
+	places := decimalPlaces
+	for places > 0 && frac%10 == 0 {
+		frac /= 10
+		places--
+	}
+	return places
+}
+
+// Function to format the decimal with a fixed number of decimal places, rounding half away from zero.
+// The digits below the precision are divided off before rounding, so the rounding can't overflow.
+func (d Decimal) StringFixed(places int) string {
+	if places < 0 {
+		places = 0
+	}
+	if places > decimalPlaces {
+		places = decimalPlaces
+	}
+	unit := pow10[decimalPlaces-places]
+	v, rest := d.abs().udivmod(unit)
+	if rest >= unit-unit/2 {
+		v, _ = v.mulAdd(1, 1)
+	}
+
+	whole, frac := v.udivmod(pow10[places])
+	s := whole.utoa()
+	if places > 0 {
+		f := strconv.FormatUint(frac, 10)
+		s += "." + strings.Repeat("0", places-len(f)) + f
+	}
+	if d.Sign() < 0 && !v.IsZero() {
+		s = "-" + s
+	}
+	return s
+}
+
+// Function to format the decimal with as many decimal places as it needs
+func (d Decimal) String() string {
+	return d.StringFixed(d.Places())
+}
+
+// Function to encode the decimal as text, so it is written as e.g. "27123.4" in JSON
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Function to decode the decimal from text
+func (d *Decimal) UnmarshalText(text []byte) error {
+	v, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// The functions below treat the 128 bits of a decimal as an unsigned magnitude
+
+// Function to get the magnitude of the decimal
+func (d Decimal) abs() Decimal {
+	if d.Sign() < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Function to compute `d`*`m`+`a` of a magnitude, `ok` is false when the result doesn't fit in 128 bits
+func (d Decimal) mulAdd(m, a uint64) (v Decimal, ok bool) {
+	carryLo, lo := bits.Mul64(d.lo, m)
+	carryHi, hi := bits.Mul64(d.hi, m)
+	hi, c1 := bits.Add64(hi, carryLo, 0)
+	lo, c2 := bits.Add64(lo, a, 0)
+	hi, c3 := bits.Add64(hi, 0, c2)
+	return Decimal{hi: hi, lo: lo}, carryHi == 0 && c1 == 0 && c3 == 0
+}
+
+// Function to divide a magnitude by `n`, returning the quotient and the remainder
+func (d Decimal) udivmod(n uint64) (Decimal, uint64) {
+	hi, r := d.hi/n, d.hi%n
+	lo, r := bits.Div64(r, d.lo, n)
+	return Decimal{hi: hi, lo: lo}, r
+}
+
+// Function to get the remainder of dividing a magnitude by another one
+func (d Decimal) umod(e Decimal) Decimal {
+	if e.IsZero() {
+		panic("decimal modulo by zero")
+	}
+	if e.hi == 0 {
+		_, r := d.udivmod(e.lo)
+		return Decimal{lo: r}
+	}
+	// the divisor has more than 64 bits, so the remainder is found by shifting and subtracting bit by bit
+	var r Decimal
+	for i := 127; i >= 0; i-- {
+		r = Decimal{hi: r.hi<<1 | r.lo>>63, lo: r.lo << 1}
+		if i >= 64 {
+			r.lo |= d.hi >> (i - 64) & 1
+		} else {
+			r.lo |= d.lo >> i & 1
+		}
+		if r.hi > e.hi || r.hi == e.hi && r.lo >= e.lo {
+			r = r.Sub(e)
+		}
+	}
+	return r
+}
+
+// Function to format a magnitude in base 10
+func (d Decimal) utoa() string {
+	if d.hi == 0 {
+		return strconv.FormatUint(d.lo, 10)
+	}
+	// 10^19 is the largest power of ten below 2^64, so the digits are split off 19 at a time
+	const chunk = 10000000000000000000
+	q, r := d.udivmod(chunk)
+	s := strconv.FormatUint(r, 10)
+	return q.utoa() + strings.Repeat("0", 19-len(s)) + s
+}
@@ -0,0 +1,111 @@
+package main
+
+import "testing"
+
+func TestParseDecimalString(t *testing.T) {
+	for _, c := range []struct {
+		in, want string
+	}{
+		{"27123.40", "27123.4"},
+		{"-0.5", "-0.5"},
+		{"+3", "3"},
+		{"1.5e-05", "0.000015"},
+		{"0.00000001", "0.00000001"},
+		{"0.000000010", "0.00000001"},
+		// beyond the 9.2e10 which 64 bits reach with 8 decimal places
+		{"92233720368.5", "92233720368.5"},
+		{"250000000000", "250000000000"},
+		{"-184467440737095516.15", "-184467440737095516.15"},
+		{"1000000000000000000000.00000001", "1000000000000000000000.00000001"},
+	} {
+		d, err := ParseDecimal(c.in)
+		if err != nil {
+			t.Errorf("ParseDecimal(%q): %v", c.in, err)
+			continue
+		}
+		if got := d.String(); got != c.want {
+			t.Errorf("ParseDecimal(%q).String() = %s, want %s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseDecimalErrors(t *testing.T) {
+	for _, in := range []string{"", "-", "1.2.3", "abc", "1e", "0.000000001", "1e40"} {
+		if d, err := ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q) = %s, want an error", in, d)
+		}
+	}
+}
+
+func TestDecimalStringFixed(t *testing.T) {
+	for _, c := range []struct {
+		in     string
+		places int
+		want   string
+	}{
+		{"27123.45", 1, "27123.5"},
+		{"-27123.45", 1, "-27123.5"},
+		{"27123.44", 1, "27123.4"},
+		{"0.004", 2, "0.00"},
+		{"-0.004", 2, "0.00"},
+		{"9.999", 2, "10.00"},
+		{"92233720368.54775807", 0, "92233720369"},
+		{"170141183460469231731687.30371588", 2, "170141183460469231731687.30"},
+	} {
+		if got := dec(t, c.in).StringFixed(c.places); got != c.want {
+			t.Errorf("%s.StringFixed(%d) = %s, want %s", c.in, c.places, got, c.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	a, b := dec(t, "27123.45"), dec(t, "-0.05")
+	if got := a.Add(b).String(); got != "27123.4" {
+		t.Errorf("Add = %s", got)
+	}
+	if got := b.Sub(a).String(); got != "-27123.5" {
+		t.Errorf("Sub = %s", got)
+	}
+	if got := a.Mod(dec(t, "10")).String(); got != "3.45" {
+		t.Errorf("Mod = %s", got)
+	}
+	if got := a.Neg().Mod(dec(t, "10")).String(); got != "-3.45" {
+		t.Errorf("Mod of a negative = %s", got)
+	}
+	if got := dec(t, "0.1").Mul(-25).String(); got != "-2.5" {
+		t.Errorf("Mul = %s", got)
+	}
+	huge := dec(t, "300000000000000000000")
+	if got := huge.Mod(dec(t, "200000000000000000000")).String(); got != "100000000000000000000" {
+		t.Errorf("Mod by more than 64 bits = %s", got)
+	}
+	if got := huge.Float64(); got != 3e20 {
+		t.Errorf("Float64 = %g", got)
+	}
+	if got := b.Float64(); got != -0.05 {
+		t.Errorf("Float64 = %g", got)
+	}
+
+	ordered := []Decimal{huge.Neg(), a.Neg(), b, {}, dec(t, "0.00000001"), a, huge}
+	for i := range ordered {
+		for j := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := ordered[i].Cmp(ordered[j]); got != want {
+				t.Errorf("%s.Cmp(%s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestDecimalPlaces(t *testing.T) {
+	for in, want := range map[string]int{"0.01": 2, "10": 0, "0": 0, "0.00000001": 8, "-2.5": 1, "250000000000.125": 3} {
+		if got := dec(t, in).Places(); got != want {
+			t.Errorf("%s.Places() = %d, want %d", in, got, want)
+		}
+	}
+}
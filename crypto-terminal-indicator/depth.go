@@ -0,0 +1,124 @@
+package main
+
+import "errors"
+
+// Maximum number of depth updates buffered while the orderbook waits for a snapshot
+const maxBufferedUpdates = 1000
+
+// Error returned when a depth update does not continue from the previous one
+var errSequenceGap = errors.New("depth update sequence gap")
+
+// `depthSync` keeps an `Orderbook` consistent with the exchange.
+// The book is seeded from a snapshot and every diff is checked for continuity against the
+// previous one. Whenever a gap is detected the book is dropped and rebuilt from a fresh snapshot.
+// Snapshots are either fetched with `fetch` or, when it is nil, sent by the exchange in the stream.
+type depthSync struct {
+	ob    *Orderbook
+	fetch func() (*DepthEvent, error)
+
+	lastUpdateID int64
+	synced       bool
+	first        bool // true until the first diff after a fetched snapshot has been applied
+	buffered     []*DepthEvent
+}
+
+// `newDepthSync()` is a constructor function for creating a new instance of `depthSync` struct
+func newDepthSync(ob *Orderbook, fetch func() (*DepthEvent, error)) *depthSync {
+	return &depthSync{
+		ob:    ob,
+		fetch: fetch,
+	}
+}
+
+// Function to handle a depth event received from the stream.
+// While the book is not synced a diff is buffered and a snapshot is requested. The returned error
+// is non-nil when fetching the snapshot failed, or when a gap was found and the exchange only
+// sends snapshots in the stream, in both cases the stream has to be resubscribed.
+func (s *depthSync) handle(u *DepthEvent) error {
+	if u.Snapshot {
+		s.load(u)
+		s.first = false
+		s.buffered = s.buffered[:0]
+		s.synced = true
+		return nil
+	}
+	if s.synced {
+		if err := s.apply(u); err == nil {
+			return nil
+		}
+		// The diff doesn't continue the book, so drop everything and start over from a snapshot
+		s.synced = false
+		s.buffered = s.buffered[:0]
+		if s.fetch == nil {
+			return errSequenceGap
+		}
+	}
+	// Without `fetch` the diffs are dropped until the exchange sends the snapshot
+	if s.fetch == nil {
+		return nil
+	}
+	if len(s.buffered) == maxBufferedUpdates {
+		s.buffered = append(s.buffered[:0], s.buffered[1:]...)
+	}
+	s.buffered = append(s.buffered, u)
+	return s.resync()
+}
+
+// Function to mark the orderbook as out of sync, e.g. after the connection was lost.
+// The book is rebuilt from a fresh snapshot as soon as the next diff arrives.
+func (s *depthSync) invalidate() {
+	s.synced = false
+	s.buffered = s.buffered[:0]
+}
+
+// Function to replace the levels of the orderbook with the ones of a snapshot
+func (s *depthSync) load(snap *DepthEvent) {
+	s.ob.reset()
+	s.ob.handleDepthResponse(snap.Asks, snap.Bids)
+	s.lastUpdateID = snap.FinalUpdateID
+}
+
+// Function to seed the orderbook from a freshly fetched snapshot and replay the buffered diffs on top of it
+func (s *depthSync) resync() error {
+	snap, err := s.fetch()
+	if err != nil {
+		return err
+	}
+	// The snapshot is older than the buffered diffs, so keep buffering and try again on the next diff
+	if snap.FinalUpdateID+1 < s.buffered[0].FirstUpdateID {
+		return nil
+	}
+
+	s.load(snap)
+	s.first = true
+	for _, u := range s.buffered {
+		if err := s.apply(u); err != nil {
+			// The buffer itself has a gap, wait for the next diff and resync again
+			s.buffered = s.buffered[:0]
+			return nil
+		}
+	}
+	s.buffered = s.buffered[:0]
+	s.synced = true
+	return nil
+}
+
+// Function to apply a single diff to the orderbook after checking its update IDs.
+// Diffs that are already contained in the book are skipped, the first diff after a fetched snapshot
+// has to start at most right after the snapshot's update ID and every later diff has to continue from the previous one.
+func (s *depthSync) apply(u *DepthEvent) error {
+	if u.FinalUpdateID < s.lastUpdateID {
+		return nil
+	}
+	if s.first {
+		if u.FirstUpdateID > s.lastUpdateID+1 {
+			return errSequenceGap
+		}
+		s.first = false
+	} else if u.PrevFinalUpdateID != s.lastUpdateID {
+		return errSequenceGap
+	}
+	s.ob.handleDepthResponse(u.Asks, u.Bids)
+	s.lastUpdateID = u.FinalUpdateID
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// Structure representing a fake Binance futures REST API serving depth snapshots.
+// The snapshots are served in turn and the last one is served again once the others were.
+type snapshotServer struct {
+	*httptest.Server
+	snapshots []string
+	fetched   atomic.Int32 // number of snapshots served so far
+}
+
+// `newSnapshotServer()` is a constructor function for creating a new instance of `snapshotServer` struct,
+// the server is closed when the test ends
+func newSnapshotServer(t *testing.T, snapshots ...string) *snapshotServer {
+	s := &snapshotServer{snapshots: snapshots}
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *snapshotServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/fapi/v1/depth" {
+		http.NotFound(w, r)
+		return
+	}
+	i := int(s.fetched.Add(1)) - 1
+	if i >= len(s.snapshots) {
+		i = len(s.snapshots) - 1
+	}
+	fmt.Fprint(w, s.snapshots[i])
+}
+
+// Function to create a market whose orderbook is seeded from the fake exchange
+func newTestMarket(srv *httptest.Server) *market {
+	return newMarket("btcusdt", newBinance("", srv.URL, srv.Client()), 10, defaultTickSize, nil)
+}
+
+// Function to decode a depth diff of the combined stream with the Binance adapter
+func depthDiff(t *testing.T, first, final, prev int64, asks, bids string) *DepthEvent {
+	t.Helper()
+	msg := fmt.Sprintf(`{"stream":"btcusdt@depth","data":{"e":"depthUpdate","U":%d,"u":%d,"pu":%d,"a":%s,"b":%s}}`,
+		first, final, prev, asks, bids)
+	events, err := newBinance("", "", nil).Decode([]byte(msg))
+	if err != nil {
+		t.Fatalf("decoding %s: %v", msg, err)
+	}
+	return events[0].(*DepthEvent)
+}
+
+// Function to check both sides of an orderbook, formatted like "[{12 3} {13 1}]"
+func checkBook(t *testing.T, ob *Orderbook, asks, bids string) {
+	t.Helper()
+	if got := fmt.Sprint(ob.getAsks(10)); got != asks {
+		t.Errorf("asks = %s, want %s", got, asks)
+	}
+	if got := fmt.Sprint(ob.getBids(10)); got != bids {
+		t.Errorf("bids = %s, want %s", got, bids)
+	}
+}
+
+func TestDepthSyncSeedsFromSnapshot(t *testing.T) {
+	srv := newSnapshotServer(t, `{"lastUpdateId":100,"bids":[["10","1"],["9","2"]],"asks":[["11","1"],["12","3"]]}`)
+	m := newTestMarket(srv.Server)
+
+	// the first diff after the snapshot removes the best ask and changes the best bid
+	if err := m.depthsync.handle(depthDiff(t, 101, 102, 100, `[["11","0"]]`, `[["10","5"]]`)); err != nil {
+		t.Fatal(err)
+	}
+	if !m.depthsync.synced {
+		t.Fatal("book not synced after the snapshot")
+	}
+	checkBook(t, m.ob, "[{12 3}]", "[{10 5} {9 2}]")
+
+	if err := m.depthsync.handle(depthDiff(t, 103, 103, 102, `[["11.5","2"]]`, `[]`)); err != nil {
+		t.Fatal(err)
+	}
+	checkBook(t, m.ob, "[{11.5 2} {12 3}]", "[{10 5} {9 2}]")
+	if n := srv.fetched.Load(); n != 1 {
+		t.Errorf("fetched %d snapshots, want 1", n)
+	}
+}
+
+func TestDepthSyncSkipsStaleDiffs(t *testing.T) {
+	srv := newSnapshotServer(t, `{"lastUpdateId":100,"bids":[["10","1"]],"asks":[["11","1"]]}`)
+	m := newTestMarket(srv.Server)
+
+	// the diff ends before the snapshot, so the snapshot already contains it
+	if err := m.depthsync.handle(depthDiff(t, 90, 95, 89, `[["11","7"]]`, `[]`)); err != nil {
+		t.Fatal(err)
+	}
+	checkBook(t, m.ob, "[{11 1}]", "[{10 1}]")
+
+	// the diff straddles the snapshot, so it is the first one to apply
+	if err := m.depthsync.handle(depthDiff(t, 96, 101, 95, `[]`, `[["10","2"]]`)); err != nil {
+		t.Fatal(err)
+	}
+	// a diff which was delivered again is skipped as well
+	if err := m.depthsync.handle(depthDiff(t, 97, 99, 96, `[["11","9"]]`, `[]`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.depthsync.handle(depthDiff(t, 102, 102, 101, `[["12","4"]]`, `[]`)); err != nil {
+		t.Fatal(err)
+	}
+	checkBook(t, m.ob, "[{11 1} {12 4}]", "[{10 2}]")
+	if !m.depthsync.synced {
+		t.Error("book not synced")
+	}
+	if n := srv.fetched.Load(); n != 1 {
+		t.Errorf("fetched %d snapshots, want 1", n)
+	}
+}
+
+func TestDepthSyncResyncsAfterGap(t *testing.T) {
+	srv := newSnapshotServer(t,
+		`{"lastUpdateId":100,"bids":[["10","1"]],"asks":[["11","1"]]}`,
+		`{"lastUpdateId":110,"bids":[["20","1"]],"asks":[["21","1"]]}`,
+	)
+	m := newTestMarket(srv.Server)
+
+	if err := m.depthsync.handle(depthDiff(t, 101, 101, 100, `[]`, `[["9","3"]]`)); err != nil {
+		t.Fatal(err)
+	}
+	checkBook(t, m.ob, "[{11 1}]", "[{10 1} {9 3}]")
+
+	// `pu` doesn't continue from the last diff, so the book is dropped and seeded from a fresh snapshot.
+	// The diff is older than the new snapshot and is skipped.
+	if err := m.depthsync.handle(depthDiff(t, 106, 107, 105, `[["11","5"]]`, `[]`)); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.fetched.Load(); n != 2 {
+		t.Fatalf("fetched %d snapshots, want 2", n)
+	}
+	if !m.depthsync.synced {
+		t.Fatal("book not synced after the gap")
+	}
+	checkBook(t, m.ob, "[{21 1}]", "[{20 1}]")
+
+	if err := m.depthsync.handle(depthDiff(t, 108, 111, 107, `[["22","2"]]`, `[]`)); err != nil {
+		t.Fatal(err)
+	}
+	checkBook(t, m.ob, "[{21 1} {22 2}]", "[{20 1}]")
+}
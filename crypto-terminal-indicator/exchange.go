@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// `Exchange` is implemented by every venue the indicator can connect to.
+// An adapter knows how to subscribe to the venue's public streams and turns its messages
+// into normalized events, so that the feed, the orderbook and the UI stay venue agnostic.
+// Symbols are passed to and returned from an adapter in lower case, e.g. `btcusdt`.
+type Exchange interface {
+	// Name of the venue as shown in the UI
+	Name() string
+	// URL of the websocket stream for the given symbols
+	StreamURL(symbols []string) string
+	// Messages to send right after connecting to subscribe to the streams of the given symbols
+	Subscribe(symbols []string) []interface{}
+	// Function to decode a raw message into events, messages which carry no market data return no events
+	Decode(msg []byte) ([]Event, error)
+}
+
+// `SnapshotFetcher` is implemented by exchanges whose depth stream only carries diffs.
+// Their orderbooks are seeded from a snapshot fetched over REST, while the other exchanges
+// send the snapshot as the first message of the depth stream.
+type SnapshotFetcher interface {
+	FetchSnapshot(symbol string) (*DepthEvent, error)
+}
+
+// `InstrumentInfo` is implemented by exchanges which publish the tick size of their instruments.
+// Prices are displayed with as many decimal places as the tick size of their symbol.
+type InstrumentInfo interface {
+	TickSizes(symbols []string) (map[string]Decimal, error)
+}
+
+// `OpenInterestFetcher` is implemented by exchanges whose open interest has to be polled over REST,
+// the other perpetual exchanges send it in the stream
+type OpenInterestFetcher interface {
+	FetchOpenInterest(symbol string) (*OpenInterestEvent, error)
+}
+
+// `Event` is a normalized market data event emitted by an `Exchange`
+type Event interface {
+	market() string
+}
+
+// Structure representing a depth diff, or the whole book when `Snapshot` is set.
+// `FirstUpdateID` and `FinalUpdateID` are the range of updates covered by the event and
+// `PrevFinalUpdateID` is the final update ID of the previous diff in the stream.
+// Levels with a zero volume are removed from the book.
+type DepthEvent struct {
+	Symbol            string
+	Snapshot          bool
+	FirstUpdateID     int64
+	FinalUpdateID     int64
+	PrevFinalUpdateID int64
+	Asks              []OrderbookEntry
+	Bids              []OrderbookEntry
+}
+
+// Structure representing a mark price update together with the index price and the current funding rate.
+// `FundingRate` is paid every `FundingInterval`, the next time at `NextFundingTime`.
+// The index price and the next funding time are zero when the exchange doesn't publish them.
+type MarkPriceEvent struct {
+	Symbol          string
+	MarkPrice       float64
+	IndexPrice      float64
+	FundingRate     string
+	FundingInterval time.Duration
+	NextFundingTime time.Time
+}
+
+// Structure representing a public trade, `BuyerMaker` is set when the seller was the aggressor
+type TradeEvent struct {
+	Symbol     string
+	Price      Decimal
+	Volume     Decimal
+	BuyerMaker bool
+	Time       time.Time
+}
+
+// Structure representing the liquidation of a position. `Side` is the side of the liquidation order,
+// so a sell closes a long position and a buy closes a short one.
+type LiquidationEvent struct {
+	Symbol string
+	Side   string
+	Price  Decimal
+	Volume Decimal
+	Time   time.Time
+}
+
+// Structure representing the open interest of a perpetual, in contracts of the symbol
+type OpenInterestEvent struct {
+	Symbol       string
+	OpenInterest float64
+	Time         time.Time
+}
+
+func (e *DepthEvent) market() string        { return e.Symbol }
+func (e *MarkPriceEvent) market() string    { return e.Symbol }
+func (e *TradeEvent) market() string        { return e.Symbol }
+func (e *LiquidationEvent) market() string  { return e.Symbol }
+func (e *OpenInterestEvent) market() string { return e.Symbol }
+
+// Structure describing an exchange adapter that can be selected with the `--exchange` flag
+type exchangeInfo struct {
+	symbols   string // symbols subscribed when `--symbols` is not given
+	perpetual bool   // the markets are perpetuals, which have liquidations and open interest
+	new       func(wsendpoint, restendpoint string, client *http.Client) Exchange
+}
+
+// All the exchange adapters keyed by their `--exchange` name
+var exchanges = map[string]exchangeInfo{
+	"binance":      {symbols: "btcusdt", perpetual: true, new: newBinance},
+	"binance-spot": {symbols: "btcusdt", new: newBinanceSpot},
+	"kraken":       {symbols: "pf_xbtusd", perpetual: true, new: newKraken},
+}
+
+// Function to look up an exchange adapter by name
+func lookupExchange(name string) (exchangeInfo, error) {
+	info, ok := exchanges[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(exchanges))
+		for n := range exchanges {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return info, fmt.Errorf("unknown exchange %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return info, nil
+}
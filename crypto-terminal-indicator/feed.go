@@ -0,0 +1,203 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Structure supervising the websocket connection to the exchange.
+// The connection is re-established with exponential backoff whenever it fails, the streams are
+// resubscribed and every orderbook is resynced from a fresh snapshot after reconnecting.
+type feed struct {
+	exchange   Exchange
+	symbols    []string
+	markets    map[string]*market // markets keyed by their lower case symbol, e.g. btcusdt
+	staleAfter time.Duration      // the feed is considered stale when no message arrived for this long
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	sleep      func(time.Duration) // waits out the backoff before redialing
+	alerts     *alertEngine        // evaluated against every published snapshot, nil when no rules are loaded
+
+	// `handle` is called from the stream and from the open interest poller, so the markets are updated under `mu`
+	mu sync.Mutex
+
+	connected   atomic.Bool
+	lastMessage atomic.Int64 // unix nanoseconds of the last received message
+	replaying   atomic.Bool  // set while a recorded session is replayed
+	replayed    atomic.Bool  // set once the replay has finished
+}
+
+// `newFeed()` is a constructor function for creating a new instance of `feed` struct
+func newFeed(ex Exchange, symbols []string, markets map[string]*market, staleAfter time.Duration) *feed {
+	return &feed{
+		exchange:   ex,
+		symbols:    symbols,
+		markets:    markets,
+		staleAfter: staleAfter,
+		minBackoff: 500 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+		sleep:      time.Sleep,
+	}
+}
+
+// Function to keep the feed connected, it never returns and is meant to be run in its own goroutine
+func (f *feed) run() {
+	backoff := f.minBackoff
+	for {
+		conn, err := f.connect()
+		if err == nil {
+			// The books missed every diff while we were disconnected, so they have to be rebuilt from snapshots
+			for _, m := range f.markets {
+				m.depthsync.invalidate()
+			}
+			connectedAt := time.Now()
+			f.lastMessage.Store(connectedAt.UnixNano())
+			f.connected.Store(true)
+			f.read(conn)
+			f.connected.Store(false)
+			conn.Close()
+
+			// A connection that stayed up for a while starts the backoff over
+			if time.Since(connectedAt) > f.maxBackoff {
+				backoff = f.minBackoff
+			}
+		}
+		f.sleep(backoff)
+		if backoff *= 2; backoff > f.maxBackoff {
+			backoff = f.maxBackoff
+		}
+	}
+}
+
+// Function to dial the exchange and subscribe to the streams of all the symbols
+func (f *feed) connect() (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(f.exchange.StreamURL(f.symbols), nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range f.exchange.Subscribe(f.symbols) {
+		if err := conn.WriteJSON(msg); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Function to read messages from the connection until it fails.
+// A read deadline of twice the stale interval makes sure a silently dead connection is dropped as well.
+func (f *feed) read(conn *websocket.Conn) {
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * f.staleAfter))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := f.handleMessage(msg); err != nil {
+			return
+		}
+	}
+}
+
+// Function to decode a raw message and apply its events to the markets.
+// Malformed messages are logged and dropped, an error is only returned when the connection has to be dropped.
+func (f *feed) handleMessage(msg []byte) error {
+	f.lastMessage.Store(time.Now().UnixNano())
+
+	events, err := f.exchange.Decode(msg)
+	if err != nil {
+		log.Printf("%s: dropping malformed message: %v", f.exchange.Name(), err)
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ev := range events {
+		if err := f.handle(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to poll the open interest of every symbol every `interval`, for the exchanges which don't stream it.
+// It never returns and is meant to be run in its own goroutine. The polled values aren't part of recorded sessions.
+func (f *feed) pollOpenInterest(fetcher OpenInterestFetcher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, s := range f.symbols {
+			ev, err := fetcher.FetchOpenInterest(s)
+			if err != nil {
+				log.Printf("%s: %v", f.exchange.Name(), err)
+				continue
+			}
+			f.mu.Lock()
+			f.handle(ev)
+			f.mu.Unlock()
+		}
+		<-ticker.C
+	}
+}
+
+// Function to feed a recorded session through the markets instead of connecting to the exchange.
+// Errors which would make a live feed reconnect are logged and the replay goes on.
+func (f *feed) runReplay(s *session, speed float64) {
+	f.replaying.Store(true)
+	f.connected.Store(true)
+	if err := s.replay(f, speed); err != nil {
+		log.Printf("replay: %v", err)
+	}
+	f.connected.Store(false)
+	f.replaying.Store(false)
+	f.replayed.Store(true)
+}
+
+// Function to apply an event to its market and publish the new state of the market.
+// An error means the orderbook can't be resynced without resubscribing, so the connection is dropped.
+func (f *feed) handle(ev Event) error {
+	m, ok := f.markets[ev.market()]
+	if !ok {
+		return nil
+	}
+	switch ev := ev.(type) {
+	// If it is a depth update, the depth sync checks the update IDs for gaps before updating the order book.
+	case *DepthEvent:
+		if err := m.depthsync.handle(ev); err != nil {
+			return err
+		}
+	// If it is a marketprice update, it updates the previous mark price, current mark price, index price and funding variables.
+	case *MarkPriceEvent:
+		m.prevMarkPrice = m.currMarkPrice
+		m.currMarkPrice = ev.MarkPrice
+		m.indexPrice = ev.IndexPrice
+		m.fundingRate = ev.FundingRate
+		m.fundingInterval = ev.FundingInterval
+		m.nextFundingTime = ev.NextFundingTime
+	// If it is a trade, it is added to the time & sales of the market.
+	case *TradeEvent:
+		m.addTrade(*ev)
+	// If it is a liquidation, it is added to the liquidations of the market.
+	case *LiquidationEvent:
+		m.addLiquidation(*ev)
+	// If it is the open interest, it is sampled together with the current mark price.
+	case *OpenInterestEvent:
+		m.addOpenInterest(*ev)
+	}
+	m.publish()
+	f.alerts.evaluate(m.load())
+	return nil
+}
+
+// Function to report if the feed is stale, which is the case while it is reconnecting
+// or when no message has arrived for longer than the stale interval
+func (f *feed) stale() bool {
+	if !f.connected.Load() {
+		return true
+	}
+	last := time.Unix(0, f.lastMessage.Load())
+	return time.Since(last) > f.staleAfter
+}
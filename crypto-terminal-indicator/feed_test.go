@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Structure representing an exchange whose open interest is polled, it serves increasing values until `stop` is closed.
+// The poller never returns, so afterwards every fetch blocks and the poller stays parked.
+type fakeOpenInterest struct {
+	stop    chan struct{}
+	fetched atomic.Int64
+}
+
+func (o *fakeOpenInterest) FetchOpenInterest(symbol string) (*OpenInterestEvent, error) {
+	select {
+	case <-o.stop:
+		select {}
+	default:
+	}
+	n := o.fetched.Add(1)
+	return &OpenInterestEvent{Symbol: symbol, OpenInterest: float64(1000 + n), Time: time.Unix(n*10, 0)}, nil
+}
+
+// The feed updates the market from the stream and from the open interest poller while the render loop reads it,
+// so this test is meant to be run with -race
+func TestFeedConcurrentReaders(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	srv := newSnapshotServer(t, `{"lastUpdateId":100,"bids":[["27000","1"]],"asks":[["27001","1"]]}`)
+	ex := newBinance("", srv.URL, srv.Client())
+	m := newMarket("btcusdt", ex, 10, defaultTickSize, []time.Duration{time.Minute})
+	f := newFeed(ex, []string{"btcusdt"}, map[string]*market{"btcusdt": m}, time.Second)
+	above := 27050.0
+	f.alerts = newAlertEngine(&alertConfig{Rules: []alertRule{{Name: "above", Metric: "mark_price", Above: &above}}}, nil)
+
+	oi := &fakeOpenInterest{stop: make(chan struct{})}
+	defer close(oi.stop)
+	go f.pollOpenInterest(oi, time.Millisecond)
+
+	const messages = 2000
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < messages; i++ {
+			id := int64(101 + i)
+			frames := []string{
+				fmt.Sprintf(`{"stream":"btcusdt@depth","data":{"U":%d,"u":%d,"pu":%d,"a":[["%d","%d"]],"b":[["%d","%d"]]}}`,
+					id, id, id-1, 27001+i%20, i%3, 27000-i%20, 1+i%4),
+				// the mark price crosses the alert every 100 messages
+				fmt.Sprintf(`{"stream":"btcusdt@markPrice","data":{"p":"%d","i":"27000","r":"0.0001","T":0}}`, 27000+i%200),
+				fmt.Sprintf(`{"stream":"btcusdt@aggTrade","data":{"p":"%d","q":"0.5","m":%t,"T":%d}}`, 27000+i%20, i%2 == 0, 1700000000000+int64(i)),
+				fmt.Sprintf(`{"stream":"btcusdt@forceOrder","data":{"o":{"s":"BTCUSDT","S":"SELL","ap":"27000","z":"1","T":%d}}}`, 1700000000000+int64(i)),
+			}
+			for _, frame := range frames {
+				if err := f.handleMessage([]byte(frame)); err != nil {
+					t.Errorf("message %d: %v", i, err)
+					return
+				}
+			}
+		}
+	}()
+
+	// the render loop reads the snapshots, switches the grouping and reads the alerts while the feed runs
+	reads := 0
+	for running := true; running; reads++ {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		s := m.load()
+		buildView(s, viewOptions{Rows: 10, TapeRows: 10, LiqRows: 10, BarWidth: 10, Interval: time.Minute, Now: time.Now()})
+		m.setGrouping(bookGroupings[reads%len(bookGroupings)])
+		f.alerts.recent()
+	}
+	wg.Wait()
+
+	s := m.load()
+	if len(s.RawAsks) == 0 || len(s.RawBids) == 0 {
+		t.Errorf("empty book after %d messages: asks %v bids %v", messages, s.RawAsks, s.RawBids)
+	}
+	if len(s.Trades) == 0 || len(s.Liquidations) == 0 {
+		t.Errorf("%d trades and %d liquidations after %d messages", len(s.Trades), len(s.Liquidations), messages)
+	}
+	if _, fired := f.alerts.recent(); fired != messages/200 {
+		t.Errorf("fired %d alerts, want %d", fired, messages/200)
+	}
+	if oi.fetched.Load() == 0 {
+		t.Error("open interest never polled")
+	}
+	t.Logf("%d reads during %d messages", reads, messages)
+}
+
+// Structure replacing the backoff of a feed, the feed waits in `sleep` until the test lets it redial.
+// `run` never returns, so the feed stays parked in `sleep` once the test stops letting it redial.
+type backoffRecorder struct {
+	backoffs chan time.Duration
+	resume   chan struct{}
+}
+
+// `newBackoffRecorder()` is a constructor function for creating a new instance of `backoffRecorder` struct,
+// it replaces the backoff of the feed
+func newBackoffRecorder(f *feed) *backoffRecorder {
+	b := &backoffRecorder{backoffs: make(chan time.Duration), resume: make(chan struct{})}
+	f.sleep = func(d time.Duration) {
+		b.backoffs <- d
+		<-b.resume
+	}
+	return b
+}
+
+// Function to wait until the feed lost its connection and backs off, the feed waits until `redial` is called
+func (b *backoffRecorder) next(t *testing.T) time.Duration {
+	t.Helper()
+	select {
+	case d := <-b.backoffs:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("the feed never backed off")
+		return 0
+	}
+}
+
+// Function to let the feed redial after its backoff
+func (b *backoffRecorder) redial() {
+	b.resume <- struct{}{}
+}
+
+// A dropped stream is redialed and the book, which missed the diffs in between, is rebuilt from a fresh snapshot
+func TestFeedRedialsAfterDrop(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	rest := newSnapshotServer(t,
+		`{"lastUpdateId":100,"bids":[["27000","1"]],"asks":[["27001","1"]]}`,
+		`{"lastUpdateId":200,"bids":[["26990","4"]],"asks":[["27020","2"]]}`,
+	)
+	// every connection gets the same diff and is closed afterwards. The diff continues the book it leaves behind,
+	// so it is the invalidation after the redial which drops the book rather than a sequence gap.
+	stream := newStreamServer(t, []string{`{"stream":"btcusdt@depth","data":{"U":101,"u":101,"pu":101,"a":[["27002","1"]],"b":[["27000","2"]]}}`})
+	ex := newBinance("ws"+strings.TrimPrefix(stream.URL, "http"), rest.URL, rest.Client())
+	m := newMarket("btcusdt", ex, 10, defaultTickSize, nil)
+	f := newFeed(ex, []string{"btcusdt"}, map[string]*market{"btcusdt": m}, time.Second)
+	b := newBackoffRecorder(f)
+	go f.run()
+
+	b.next(t)
+	if !f.stale() {
+		t.Error("the feed isn't stale while it is reconnecting")
+	}
+	if got := fmt.Sprint(m.load().RawAsks, m.load().RawBids); got != "[{27001 1} {27002 1}] [{27000 2}]" || rest.fetched.Load() != 1 {
+		t.Errorf("book after the first connection = %s from %d snapshots", got, rest.fetched.Load())
+	}
+
+	// the diff of the second connection is older than the fresh snapshot, the book is the snapshot alone
+	b.redial()
+	b.next(t)
+	if got := fmt.Sprint(m.load().RawAsks, m.load().RawBids); got != "[{27020 2}] [{26990 4}]" || rest.fetched.Load() != 2 {
+		t.Errorf("book after the redial = %s from %d snapshots, want it rebuilt from the second one", got, rest.fetched.Load())
+	}
+}
+
+// The backoff doubles up to its maximum with every dropped connection and starts over after one which stayed up
+func TestFeedBackoff(t *testing.T) {
+	const hold = 4 // the connection which stays up for longer than the maximum backoff
+	var conns atomic.Int32
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if conns.Add(1)-1 == hold {
+			time.Sleep(100 * time.Millisecond)
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	t.Cleanup(srv.Close)
+
+	ex := newBinance("ws"+strings.TrimPrefix(srv.URL, "http"), "", nil)
+	f := newFeed(ex, []string{"btcusdt"}, map[string]*market{}, time.Second)
+	f.minBackoff, f.maxBackoff = 10*time.Millisecond, 50*time.Millisecond
+	b := newBackoffRecorder(f)
+	go f.run()
+
+	want := []time.Duration{10, 20, 40, 50, 10, 20}
+	for i, w := range want {
+		if d := b.next(t); d != w*time.Millisecond {
+			t.Errorf("backoff %d = %s, want %s", i, d, w*time.Millisecond)
+		}
+		if i < len(want)-1 {
+			b.redial()
+		}
+	}
+	if n := conns.Load(); n != int32(len(want)) {
+		t.Errorf("dialed %d times, want %d", n, len(want))
+	}
+}
+
+func TestFeedStale(t *testing.T) {
+	f := newFeed(newBinance("", "", nil), []string{"btcusdt"}, map[string]*market{}, time.Second)
+	if !f.stale() {
+		t.Error("a feed which never connected isn't stale")
+	}
+	f.connected.Store(true)
+	f.lastMessage.Store(time.Now().UnixNano())
+	if f.stale() {
+		t.Error("a connected feed with a fresh message is stale")
+	}
+	f.lastMessage.Store(time.Now().Add(-2 * time.Second).UnixNano())
+	if !f.stale() {
+		t.Error("a feed without a message for longer than the stale interval isn't stale")
+	}
+	// any message, even one for a symbol the feed has no market of, keeps the feed fresh
+	if err := f.handleMessage([]byte(`{"stream":"ethusdt@markPrice","data":{"p":"2000","i":"2000","r":"0.0001","T":0}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if f.stale() {
+		t.Error("the feed is stale right after a message")
+	}
+	f.connected.Store(false)
+	if !f.stale() {
+		t.Error("a disconnected feed isn't stale")
+	}
+}
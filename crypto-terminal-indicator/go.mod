@@ -4,7 +4,9 @@ go 1.20
 
 require (
 	github.com/gizak/termui/v3 v3.1.0
+	github.com/google/btree v1.1.3
 	github.com/gorilla/websocket v1.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Structure serving the state of the markets over HTTP when running without a terminal.
+// Every request reads the latest published snapshots, so it never blocks the feed.
+type server struct {
+	exchange string
+	symbols  []string
+	markets  map[string]*market
+	feed     *feed
+}
+
+// Structure representing the response of the `/book` endpoint
+type bookResponse struct {
+	Exchange      string           `json:"exchange"`
+	Symbol        string           `json:"symbol"`
+	Asks          []OrderbookEntry `json:"asks"` // lowest price first
+	Bids          []OrderbookEntry `json:"bids"` // highest price first
+	MarkPrice     float64          `json:"mark_price"`
+	FundingRate   string           `json:"funding_rate"`
+	Stale         bool             `json:"stale"`
+	LastMessageAt time.Time        `json:"last_message_at"`
+}
+
+// `newServer()` is a constructor function for creating a new instance of `server` struct
+func newServer(exchange string, symbols []string, markets map[string]*market, f *feed) *server {
+	return &server{
+		exchange: exchange,
+		symbols:  symbols,
+		markets:  markets,
+		feed:     f,
+	}
+}
+
+// Function to build the routes of the server
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/book", s.handleBook)
+	return mux
+}
+
+// Function to serve the markets until the process is interrupted, then the server is shut down gracefully
+func (s *server) serve(addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("serving metrics on %s", addr)
+		errc <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdown)
+}
+
+// Function to write the markets in the Prometheus text exposition format.
+// Metrics which aren't known yet, e.g. the best bid before the book is synced, are left out.
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	up := 1.0
+	if s.feed.stale() {
+		up = 0
+	}
+	labels := fmt.Sprintf("exchange=%q", s.exchange)
+	writeMetric(w, "crypto_feed_up", "Whether the feed is connected and not stale.", labels, up)
+	if last := s.feed.lastMessage.Load(); last > 0 {
+		writeMetric(w, "crypto_feed_last_message_age_seconds", "Seconds since the last message of the feed.",
+			labels, time.Since(time.Unix(0, last)).Seconds())
+	}
+
+	gauges := []struct {
+		name, help string
+		value      func(m *marketSnapshot) (float64, bool)
+	}{
+		{"crypto_best_bid", "Best bid price.", alertMetrics["best_bid"]},
+		{"crypto_best_ask", "Best ask price.", alertMetrics["best_ask"]},
+		{"crypto_spread", "Difference between the best ask and the best bid.", alertMetrics["spread"]},
+		{"crypto_mark_price", "Mark price.", alertMetrics["mark_price"]},
+		{"crypto_funding_rate", "Current funding rate.", alertMetrics["funding_rate"]},
+		{"crypto_best_bid_volume", "Volume at the best bid.", func(m *marketSnapshot) (float64, bool) {
+			return m.BestBid.Volume.Float64(), m.BestBid.Price.Sign() > 0
+		}},
+		{"crypto_best_ask_volume", "Volume at the best ask.", func(m *marketSnapshot) (float64, bool) {
+			return m.BestAsk.Volume.Float64(), m.BestAsk.Price.Sign() > 0
+		}},
+		{"crypto_open_interest", "Open interest in contracts.", func(m *marketSnapshot) (float64, bool) {
+			if n := len(m.OpenInterest); n > 0 {
+				return m.OpenInterest[n-1].OpenInterest, true
+			}
+			return 0, false
+		}},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, symbol := range s.symbols {
+			m := s.markets[symbol].load()
+			if v, ok := g.value(m); ok {
+				fmt.Fprintf(w, "%s{exchange=%q,symbol=%q} %s\n", g.name, s.exchange, m.Symbol, formatMetric(v))
+			}
+		}
+	}
+}
+
+// Function to write a gauge with a single sample
+func writeMetric(w io.Writer, name, help, labels string, v float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s{%s} %s\n", name, help, name, name, labels, formatMetric(v))
+}
+
+// Function to format a sample value the way Prometheus expects it
+func formatMetric(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Function to write the orderbook of a symbol as JSON, e.g. `/book?symbol=btcusdt&depth=5`.
+// The symbol defaults to the first subscribed one and the depth to all the levels kept in the snapshots.
+// The snapshots keep the `--depth` flag of levels per side, a deeper depth is rejected rather than cut short.
+func (s *server) handleBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	symbol := strings.ToLower(r.URL.Query().Get("symbol"))
+	if symbol == "" {
+		symbol = s.symbols[0]
+	}
+	mk, ok := s.markets[symbol]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown symbol %q", symbol), http.StatusNotFound)
+		return
+	}
+	m := mk.load()
+
+	depth := mk.depth
+	if d := r.URL.Query().Get("depth"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("invalid depth %q", d), http.StatusBadRequest)
+			return
+		}
+		if n > mk.depth {
+			http.Error(w, fmt.Sprintf("depth %d is above the %d levels kept per side, raise --depth", n, mk.depth), http.StatusBadRequest)
+			return
+		}
+		depth = n
+	}
+	asks, bids := m.Asks, m.Bids
+	if len(asks) > depth {
+		asks = asks[:depth]
+	}
+	if len(bids) > depth {
+		bids = bids[:depth]
+	}
+
+	resp := bookResponse{
+		Exchange:      s.exchange,
+		Symbol:        m.Symbol,
+		Asks:          asks,
+		Bids:          bids,
+		MarkPrice:     m.CurrMarkPrice,
+		FundingRate:   m.FundingRate,
+		Stale:         s.feed.stale(),
+		LastMessageAt: time.Unix(0, s.feed.lastMessage.Load()).UTC(),
+	}
+	// the JSON arrays are never null, even before the book is synced
+	if resp.Asks == nil {
+		resp.Asks = []OrderbookEntry{}
+	}
+	if resp.Bids == nil {
+		resp.Bids = []OrderbookEntry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("book: %v", err)
+	}
+}
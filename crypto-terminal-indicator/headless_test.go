@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function to build a server over a synced btcusdt market keeping 3 levels a side and an ethusdt one which isn't synced yet
+func newTestServer(t *testing.T) *server {
+	btc := newMarket("btcusdt", nil, 3, dec(t, "0.1"), nil)
+	for i, level := range priceLevels(t, "100:1", "100.1:2", "100.2:3", "100.3:4") {
+		btc.ob.addAsk(level.Price, level.Volume)
+		btc.ob.addBid(dec(t, "99.9").Sub(dec(t, "0.1").Mul(int64(i))), level.Volume)
+	}
+	btc.currMarkPrice = 99.95
+	btc.fundingRate = "0.00010000"
+	btc.publish()
+
+	symbols := []string{"btcusdt", "ethusdt"}
+	markets := map[string]*market{
+		"btcusdt": btc,
+		"ethusdt": newMarket("ethusdt", nil, 3, dec(t, "0.01"), nil),
+	}
+	f := newFeed(nil, symbols, markets, time.Minute)
+	f.connected.Store(true)
+	f.lastMessage.Store(time.Now().UnixNano())
+	return newServer("Binance Futures", symbols, markets, f)
+}
+
+// Function to send a request to the routes of the server and return the recorded response
+func serveRequest(s *server, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestHandleMetrics(t *testing.T) {
+	s := newTestServer(t)
+	rec := serveRequest(s, http.MethodGet, "/metrics")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("GET /metrics = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	for _, line := range []string{
+		`# TYPE crypto_feed_up gauge`,
+		`crypto_feed_up{exchange="Binance Futures"} 1`,
+		`# HELP crypto_best_bid Best bid price.`,
+		`crypto_best_bid{exchange="Binance Futures",symbol="BTCUSDT"} 99.9`,
+		`crypto_best_ask{exchange="Binance Futures",symbol="BTCUSDT"} 100`,
+		`crypto_spread{exchange="Binance Futures",symbol="BTCUSDT"} 0.1`,
+		`crypto_best_ask_volume{exchange="Binance Futures",symbol="BTCUSDT"} 1`,
+		`crypto_mark_price{exchange="Binance Futures",symbol="BTCUSDT"} 99.95`,
+		`crypto_funding_rate{exchange="Binance Futures",symbol="BTCUSDT"} 0.0001`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics miss %q:\n%s", line, body)
+		}
+	}
+	// the ethusdt book isn't synced and its funding rate isn't known, none of its samples are written
+	if strings.Contains(body, `symbol="ETHUSDT"`) {
+		t.Errorf("metrics have samples of the unsynced market:\n%s", body)
+	}
+	if strings.Contains(body, "crypto_open_interest{") {
+		t.Errorf("metrics have open interest samples without any polled:\n%s", body)
+	}
+
+	s.feed.connected.Store(false)
+	if body := serveRequest(s, http.MethodGet, "/metrics").Body.String(); !strings.Contains(body, `crypto_feed_up{exchange="Binance Futures"} 0`+"\n") {
+		t.Errorf("metrics of a disconnected feed:\n%s", body)
+	}
+}
+
+func TestHandleBook(t *testing.T) {
+	s := newTestServer(t)
+	for _, c := range []struct {
+		target     string
+		symbol     string
+		asks, bids int
+	}{
+		{"/book", "BTCUSDT", 3, 3},
+		{"/book?symbol=BTCUSDT&depth=2", "BTCUSDT", 2, 2},
+		{"/book?depth=3", "BTCUSDT", 3, 3},
+		// the arrays of a book which isn't synced are empty rather than null
+		{"/book?symbol=ethusdt", "ETHUSDT", 0, 0},
+	} {
+		rec := serveRequest(s, http.MethodGet, c.target)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("GET %s = %d %s", c.target, rec.Code, rec.Body)
+			continue
+		}
+		var book bookResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &book); err != nil {
+			t.Fatal(err)
+		}
+		if book.Symbol != c.symbol || len(book.Asks) != c.asks || len(book.Bids) != c.bids || book.Stale {
+			t.Errorf("GET %s = %s", c.target, rec.Body)
+		}
+		if book.Asks == nil || !strings.Contains(rec.Body.String(), `"asks":[`) {
+			t.Errorf("GET %s has null asks: %s", c.target, rec.Body)
+		}
+	}
+
+	rec := serveRequest(s, http.MethodGet, "/book?depth=2")
+	var book bookResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &book); err != nil {
+		t.Fatal(err)
+	}
+	if book.Asks[1].Price.String() != "100.1" || book.Bids[1].Price.String() != "99.8" || book.MarkPrice != 99.95 || book.FundingRate != "0.00010000" {
+		t.Errorf("GET /book?depth=2 = %s", rec.Body)
+	}
+}
+
+func TestHandleBookErrors(t *testing.T) {
+	s := newTestServer(t)
+	for _, c := range []struct {
+		method, target string
+		code           int
+	}{
+		{http.MethodGet, "/book?depth=ten", http.StatusBadRequest},
+		{http.MethodGet, "/book?depth=0", http.StatusBadRequest},
+		{http.MethodGet, "/book?depth=-1", http.StatusBadRequest},
+		// the snapshots keep 3 levels a side, a deeper book isn't cut short silently
+		{http.MethodGet, "/book?depth=4", http.StatusBadRequest},
+		{http.MethodGet, "/book?symbol=solusdt", http.StatusNotFound},
+		{http.MethodPost, "/book", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/book?symbol=btcusdt", http.StatusMethodNotAllowed},
+	} {
+		rec := serveRequest(s, c.method, c.target)
+		if rec.Code != c.code {
+			t.Errorf("%s %s = %d %s, want %d", c.method, c.target, rec.Code, rec.Body, c.code)
+		}
+		if c.code == http.StatusMethodNotAllowed && rec.Header().Get("Allow") != http.MethodGet {
+			t.Errorf("%s %s has Allow %q", c.method, c.target, rec.Header().Get("Allow"))
+		}
+	}
+	if body := serveRequest(s, http.MethodGet, "/book?depth=4").Body.String(); !strings.Contains(body, "above the 3 levels") {
+		t.Errorf("GET /book?depth=4 = %q, want the limit reported", body)
+	}
+}
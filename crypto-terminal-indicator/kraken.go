@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default endpoints of the Kraken futures API
+const (
+	krakenWSEndpoint   = "wss://futures.kraken.com/ws/v1"
+	krakenRESTEndpoint = "https://futures.kraken.com"
+)
+
+// Funding interval of the Kraken perpetuals, the relative funding rate is the rate of one interval
+const krakenFundingInterval = time.Hour
+
+// Structure representing a price level of a Kraken book snapshot
+// Prices and volumes are kept as `json.Number`, so they can be parsed into exact decimals.
+type KrakenLevel struct {
+	Price  json.Number `json:"price"`
+	Volume json.Number `json:"qty"`
+}
+
+// Structure representing a trade of a Kraken trade snapshot.
+// `Type` is "liquidation" when the taker order liquidated a position, "fill" for a regular trade.
+type KrakenTrade struct {
+	Side   string      `json:"side"`
+	Type   string      `json:"type"`
+	Price  json.Number `json:"price"`
+	Volume json.Number `json:"qty"`
+	Time   int64       `json:"time"`
+}
+
+// Structure representing any message of the Kraken futures feeds.
+// The `feed` field determines which of the other fields are set,
+// `event` is only set on subscription acks, heartbeats and info messages.
+type KrakenMessage struct {
+	Event     string `json:"event"`
+	Feed      string `json:"feed"`
+	ProductID string `json:"product_id"`
+	Seq       int64  `json:"seq"`
+
+	// book_snapshot
+	Asks []KrakenLevel `json:"asks"`
+	Bids []KrakenLevel `json:"bids"`
+
+	// trade_snapshot
+	Trades []KrakenTrade `json:"trades"`
+
+	// book and trade
+	Side   string      `json:"side"`
+	Type   string      `json:"type"`
+	Price  json.Number `json:"price"`
+	Volume json.Number `json:"qty"`
+	Time   int64       `json:"time"`
+
+	// ticker
+	MarkPrice           float64 `json:"markPrice"`
+	Index               float64 `json:"index"`
+	RelativeFundingRate float64 `json:"relative_funding_rate"`
+	NextFundingRateTime int64   `json:"next_funding_rate_time"`
+	OpenInterest        float64 `json:"openInterest"`
+}
+
+// Structure representing the instruments from the Kraken futures REST API
+type KrakenInstruments struct {
+	Instruments []struct {
+		Symbol   string      `json:"symbol"`
+		TickSize json.Number `json:"tickSize"`
+	} `json:"instruments"`
+}
+
+// Structure representing the Kraken futures adapter.
+// The feeds are subscribed with messages after connecting and the book snapshot is sent in the stream,
+// each book message carries a sequence number which increases by one per message.
+type kraken struct {
+	wsendpoint   string
+	restendpoint string
+	client       *http.Client
+}
+
+// `newKraken()` is a constructor function for creating a new instance of the Kraken adapter,
+// empty endpoints fall back to the production API
+func newKraken(wsendpoint, restendpoint string, client *http.Client) Exchange {
+	if wsendpoint == "" {
+		wsendpoint = krakenWSEndpoint
+	}
+	if restendpoint == "" {
+		restendpoint = krakenRESTEndpoint
+	}
+	return &kraken{
+		wsendpoint:   wsendpoint,
+		restendpoint: restendpoint,
+		client:       client,
+	}
+}
+
+func (k *kraken) Name() string { return "Kraken" }
+
+func (k *kraken) StreamURL(symbols []string) string { return k.wsendpoint }
+
+// Function to build the subscriptions to the book, ticker and trade feeds of every symbol
+func (k *kraken) Subscribe(symbols []string) []interface{} {
+	products := make([]string, len(symbols))
+	for i, s := range symbols {
+		products[i] = strings.ToUpper(s)
+	}
+	var msgs []interface{}
+	for _, feed := range []string{"book", "ticker", "trade"} {
+		msgs = append(msgs, map[string]interface{}{
+			"event":       "subscribe",
+			"feed":        feed,
+			"product_ids": products,
+		})
+	}
+	return msgs
+}
+
+// Function to decode a message of the Kraken feeds.
+// Subscription acks and info messages carry an `event` and heartbeats no `product_id`, they return no events.
+func (k *kraken) Decode(msg []byte) ([]Event, error) {
+	var m KrakenMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return nil, err
+	}
+	// the acks echo the feed they subscribed to, e.g. {"event":"subscribed","feed":"book","product_ids":[...]}
+	if m.Event != "" || m.ProductID == "" {
+		return nil, nil
+	}
+	symbol := strings.ToLower(m.ProductID)
+
+	switch m.Feed {
+	case "book_snapshot":
+		asks, err := krakenEntries(m.Asks)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", m.Feed, m.ProductID, err)
+		}
+		bids, err := krakenEntries(m.Bids)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", m.Feed, m.ProductID, err)
+		}
+		return []Event{&DepthEvent{
+			Symbol:        symbol,
+			Snapshot:      true,
+			FinalUpdateID: m.Seq,
+			Asks:          asks,
+			Bids:          bids,
+		}}, nil
+	case "book":
+		ev := &DepthEvent{
+			Symbol:            symbol,
+			FirstUpdateID:     m.Seq,
+			FinalUpdateID:     m.Seq,
+			PrevFinalUpdateID: m.Seq - 1,
+		}
+		entry, err := krakenEntry(KrakenLevel{Price: m.Price, Volume: m.Volume})
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", m.Feed, m.ProductID, err)
+		}
+		level := []OrderbookEntry{entry}
+		if m.Side == "sell" {
+			ev.Asks = level
+		} else {
+			ev.Bids = level
+		}
+		return []Event{ev}, nil
+	case "ticker":
+		ev := &MarkPriceEvent{
+			Symbol:          symbol,
+			MarkPrice:       m.MarkPrice,
+			IndexPrice:      m.Index,
+			FundingRate:     strconv.FormatFloat(m.RelativeFundingRate, 'f', 8, 64),
+			FundingInterval: krakenFundingInterval,
+		}
+		if m.NextFundingRateTime > 0 {
+			ev.NextFundingTime = time.UnixMilli(m.NextFundingRateTime)
+		}
+		events := []Event{ev}
+		// the open interest is streamed with the ticker, so it never has to be polled
+		if m.OpenInterest > 0 {
+			oi := &OpenInterestEvent{Symbol: symbol, OpenInterest: m.OpenInterest, Time: time.UnixMilli(m.Time)}
+			if m.Time == 0 {
+				oi.Time = time.Now()
+			}
+			events = append(events, oi)
+		}
+		return events, nil
+	case "trade":
+		t := KrakenTrade{Side: m.Side, Type: m.Type, Price: m.Price, Volume: m.Volume, Time: m.Time}
+		ev, err := krakenTradeEvent(symbol, t)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", m.Feed, m.ProductID, err)
+		}
+		if t.Type == "liquidation" {
+			return []Event{ev, krakenLiquidation(ev, t)}, nil
+		}
+		return []Event{ev}, nil
+	// The snapshot holds the latest trades, they are emitted oldest first like live trades
+	case "trade_snapshot":
+		trades := make([]*TradeEvent, len(m.Trades))
+		for i, t := range m.Trades {
+			ev, err := krakenTradeEvent(symbol, t)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", m.Feed, m.ProductID, err)
+			}
+			trades[i] = ev
+		}
+		sort.SliceStable(trades, func(i, j int) bool { return trades[i].Time.Before(trades[j].Time) })
+		events := make([]Event, len(trades))
+		for i, t := range trades {
+			events[i] = t
+		}
+		return events, nil
+	}
+	return nil, nil
+}
+
+// Function to fetch the tick sizes of the given symbols from the instruments endpoint
+func (k *kraken) TickSizes(symbols []string) (map[string]Decimal, error) {
+	resp, err := k.client.Get(k.restendpoint + "/derivatives/api/v3/instruments")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("instruments: unexpected status %s", resp.Status)
+	}
+	var info KrakenInstruments
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("instruments: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		wanted[s] = true
+	}
+	ticks := make(map[string]Decimal, len(symbols))
+	for _, inst := range info.Instruments {
+		symbol := strings.ToLower(inst.Symbol)
+		if !wanted[symbol] || inst.TickSize == "" {
+			continue
+		}
+		tick, err := ParseDecimal(inst.TickSize.String())
+		if err != nil {
+			return nil, fmt.Errorf("instruments: tick size of %s: %w", inst.Symbol, err)
+		}
+		ticks[symbol] = tick
+	}
+	return ticks, nil
+}
+
+// Function to convert the levels of a book snapshot into orderbook entries
+func krakenEntries(levels []KrakenLevel) ([]OrderbookEntry, error) {
+	entries := make([]OrderbookEntry, len(levels))
+	for i, l := range levels {
+		entry, err := krakenEntry(l)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// Function to convert a trade into a trade event
+func krakenTradeEvent(symbol string, t KrakenTrade) (*TradeEvent, error) {
+	entry, err := krakenEntry(KrakenLevel{Price: t.Price, Volume: t.Volume})
+	if err != nil {
+		return nil, err
+	}
+	return &TradeEvent{
+		Symbol: symbol,
+		Price:  entry.Price,
+		Volume: entry.Volume,
+		// the side is the one of the taker, so a sell means the buyer was the maker
+		BuyerMaker: t.Side == "sell",
+		Time:       time.UnixMilli(t.Time),
+	}, nil
+}
+
+// Function to get the liquidation of a trade whose taker order liquidated a position
+func krakenLiquidation(ev *TradeEvent, t KrakenTrade) *LiquidationEvent {
+	return &LiquidationEvent{
+		Symbol: ev.Symbol,
+		Side:   t.Side,
+		Price:  ev.Price,
+		Volume: ev.Volume,
+		Time:   ev.Time,
+	}
+}
+
+// Function to parse the price and volume of a level into an orderbook entry
+func krakenEntry(l KrakenLevel) (OrderbookEntry, error) {
+	price, err := ParseDecimal(l.Price.String())
+	if err != nil {
+		return OrderbookEntry{}, fmt.Errorf("level price: %w", err)
+	}
+	volume, err := ParseDecimal(l.Volume.String())
+	if err != nil {
+		return OrderbookEntry{}, fmt.Errorf("level volume: %w", err)
+	}
+	return OrderbookEntry{Price: price, Volume: volume}, nil
+}
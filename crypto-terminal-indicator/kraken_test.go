@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKrakenDecode(t *testing.T) {
+	trade := &TradeEvent{
+		Symbol:     "pf_xbtusd",
+		Price:      dec(t, "36480.5"),
+		Volume:     dec(t, "0.25"),
+		BuyerMaker: true,
+		Time:       time.UnixMilli(1700000000300),
+	}
+	runDecodeCases(t, newKraken("", "", nil), "kraken", []decodeCase{
+		{fixture: "book_snapshot.json", want: []Event{&DepthEvent{
+			Symbol:        "pf_xbtusd",
+			Snapshot:      true,
+			FinalUpdateID: 326,
+			Asks:          []OrderbookEntry{{Price: dec(t, "36501"), Volume: dec(t, "2")}},
+			Bids: []OrderbookEntry{
+				{Price: dec(t, "36500.5"), Volume: dec(t, "1.2")},
+				{Price: dec(t, "36500"), Volume: dec(t, "0.5")},
+			},
+		}}},
+		// every book message is a single level, the sequence numbers follow each other
+		{fixture: "book.json", want: []Event{&DepthEvent{
+			Symbol:            "pf_xbtusd",
+			FirstUpdateID:     327,
+			FinalUpdateID:     327,
+			PrevFinalUpdateID: 326,
+			Asks:              []OrderbookEntry{{Price: dec(t, "36501"), Volume: Decimal{}}},
+		}}},
+		{fixture: "ticker.json", want: []Event{
+			&MarkPriceEvent{
+				Symbol:          "pf_xbtusd",
+				MarkPrice:       36500.25,
+				IndexPrice:      36490.5,
+				FundingRate:     "0.00001260",
+				FundingInterval: time.Hour,
+				NextFundingTime: time.UnixMilli(1700003600000),
+			},
+			&OpenInterestEvent{Symbol: "pf_xbtusd", OpenInterest: 1234.5678, Time: time.UnixMilli(1700000000200)},
+		}},
+		{fixture: "trade.json", want: []Event{trade, &LiquidationEvent{
+			Symbol: "pf_xbtusd",
+			Side:   "sell",
+			Price:  trade.Price,
+			Volume: trade.Volume,
+			Time:   trade.Time,
+		}}},
+		// the snapshot lists the newest trade first, the events are emitted oldest first
+		{fixture: "trade_snapshot.json", want: []Event{
+			&TradeEvent{Symbol: "pf_xbtusd", Price: dec(t, "36489.5"), Volume: dec(t, "0.3"), BuyerMaker: true, Time: time.UnixMilli(1700000000200)},
+			&TradeEvent{Symbol: "pf_xbtusd", Price: dec(t, "36490"), Volume: dec(t, "0.1"), Time: time.UnixMilli(1700000000250)},
+		}},
+		{fixture: "subscribed_book.json"},
+		{fixture: "subscribed_ticker.json"},
+		{fixture: "heartbeat.json"},
+		{fixture: "info.json"},
+	})
+}
@@ -1,269 +1,415 @@
-package main
-
-import (
-	"fmt"
-	"log"
-	"sort"
-	"strconv"
-	"time"
-
-	ui "github.com/gizak/termui/v3"
-	"github.com/gizak/termui/v3/widgets"
-	"github.com/gorilla/websocket"
-)
-
-const wsendpoint = "wss://fstream.binance.com/stream?streams=btcusdt@markPrice/btcusdt@depth"
-
-// Global variables
-var (
-	WIDTH         = 0
-	HEIGHT        = 0
-	currMarkPrice = 0.0
-	prevMarkPrice = 0.0
-	fundingRate   = "n/a"
-	ARROW_UP      = "↑"
-	ARROW_DOWN    = "↓"
-)
-
-// Structure representing an orderbook entry
-type OrderbookEntry struct {
-	Price  float64
-	Volume float64
-}
-
-// Custom sorting interface for orderbook entries by best ask
-type byBestAsk []OrderbookEntry
-
-func (a byBestAsk) Len() int           { return len(a) }
-func (a byBestAsk) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a byBestAsk) Less(i, j int) bool { return a[i].Price < a[j].Price }
-
-// Custom sorting interface for orderbook entries by best bid
-type byBestBid []OrderbookEntry
-
-func (a byBestBid) Len() int           { return len(a) }
-func (a byBestBid) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a byBestBid) Less(i, j int) bool { return a[i].Price > a[j].Price }
-
-// Structure representing the orderbook
-type Orderbook struct {
-	Asks map[float64]float64
-	Bids map[float64]float64
-}
-
-// `NewOrderbook()` is a constructor function for creating a new insatnce of `Orderbook` struct
-func NewOrderbook() *Orderbook {
-	return &Orderbook{
-		Asks: make(map[float64]float64),
-		Bids: make(map[float64]float64),
-	}
-}
-
-// Function to handle depth response for the received from the Binance API so as to maintain the orderbook
-// It takes two slices, asks and bids, as input. It iterates over the asks and bids slices,
-// parses the price and volume values from each entry,
-// and calls the addAsk() and addBid() methods to update the order book accordingly.
-func (ob *Orderbook) handleDepthResponse(asks, bids []interface{}) {
-	for _, v := range asks {
-		ask := v.([]interface{})
-		price, _ := strconv.ParseFloat(ask[0].(string), 64)
-		volume, _ := strconv.ParseFloat(ask[1].(string), 64)
-		ob.addAsk(price, volume)
-	}
-	for _, v := range bids {
-		bid := v.([]interface{})
-		price, _ := strconv.ParseFloat(bid[0].(string), 64)
-		volume, _ := strconv.ParseFloat(bid[1].(string), 64)
-		ob.addBid(price, volume)
-	}
-}
-
-// Function to add a bid to the orderbook
-func (ob *Orderbook) addBid(price, volume float64) {
-	if _, ok := ob.Bids[price]; ok {
-		// If the volume is zero, the entry is deleted from the respective map.
-		// Otherwise, it adds or updates the volume for the given price.
-		if volume == 0.0 {
-			delete(ob.Bids, price)
-			return
-		}
-	}
-	ob.Bids[price] = volume
-}
-
-// Function to add an ask to the orderbook
-func (ob *Orderbook) addAsk(price, volume float64) {
-	if volume == 0.0 {
-		delete(ob.Asks, price)
-		return
-	}
-	ob.Asks[price] = volume
-}
-
-// The two methods `getBids()` and `getAsks()`, Function to get the best `bids` and `asks` entries from the orderbook
-func (ob *Orderbook) getBids() []OrderbookEntry {
-	depth := 10
-	entries := make(byBestBid, len(ob.Bids)) // creates a slice entries of type byBestBid (custom sorting interface) with a length equal to the number of bids in the Bids map.
-	i := 0
-	// It iterates over the Bids map and filters out entries with zero volume.
-	for price, volume := range ob.Bids {
-		if volume == 0 {
-			continue
-		}
-		// Then, it assigns the price and volume values to the corresponding OrderbookEntry struct and adds it to the entries slice.
-		entries[i] = OrderbookEntry{
-			Price:  price,
-			Volume: volume,
-		}
-		i++
-	}
-	//  Finally, it sorts the entries slice and returns a portion of it based on the depth value (in this case, 10).
-	sort.Sort(entries)
-	if len(entries) >= depth {
-		return entries[:depth]
-	}
-	return entries
-}
-
-// Function to get the best asks from the orderbook
-func (ob *Orderbook) getAsks() []OrderbookEntry {
-	depth := 10
-	entries := make(byBestAsk, len(ob.Asks))
-	i := 0
-	for price, volume := range ob.Asks {
-		entries[i] = OrderbookEntry{
-			Price:  price,
-			Volume: volume,
-		}
-		i++
-	}
-	sort.Sort(entries)
-	if len(entries) >= depth {
-		return entries[:depth]
-	}
-	return entries
-}
-
-// Structure representing the trade result from Binance
-type BinanceTradeResult struct {
-	Data struct {
-		Price string `json:"p"`
-	} `json:"data"`
-}
-
-// Structure representing the depth result from Binance API
-type BinanceDepthResult struct {
-	Asks [][]string `json:"a"`
-	Bids [][]string `json:"b"`
-}
-
-// Structure representing the depth response from Binance
-type BinanceDepthResponse struct {
-	Stream string             `json:"stream"`
-	Data   BinanceDepthResult `json:"data"`
-}
-
-func main() {
-	if err := ui.Init(); err != nil {
-		log.Fatal(err)
-	}
-
-	// This code establishes a WebSocket connection to the Binance API using the github.com/gorilla/websocket
-	conn, _, err := websocket.DefaultDialer.Dial(wsendpoint, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	var (
-		ob     = NewOrderbook()
-		result map[string]interface{} // `result` variable will be used to store the JSON response from the WebSocket connection.
-	)
-
-	go func() {
-		// goroutine to continuously read JSON messages from the WebSocket connection using the `conn.ReadJSON()` method.
-		// It parses the received JSON into the `result` map
-		for {
-			if err := conn.ReadJSON(&result); err != nil {
-				log.Fatal(err)
-			}
-			// below `stream` variable denotes the `stream` field in the received JSON to determine if it is a depth update or a mark price update.
-			stream := result["stream"].(string) 
-			// If it is a depth update, it extracts the asks and bids data from the result map and calls the ob.handleDepthResponse() method to update the order book.
-			if stream == "btcusdt@depth" {
-				data := result["data"].(map[string]interface{})
-				asks := data["a"].([]interface{})
-				bids := data["b"].([]interface{})
-				ob.handleDepthResponse(asks, bids)
-			}
-			// If it is a marketprice update, it updates the previous mark price, current mark price, and funding rate variables.
-			if stream == "btcusdt@markPrice" {
-				prevMarkPrice = currMarkPrice
-				data := result["data"].(map[string]interface{})
-				priceStr := data["p"].(string)
-				fundingRate = data["r"].(string)
-				currMarkPrice, _ = strconv.ParseFloat(priceStr, 64)
-			}
-		}
-	}()
-
-	isrunning := true
-
-	margin := 2
-	pheight := 3
-
-	pticker := widgets.NewParagraph()
-	pticker.Title = "Binancef"
-	pticker.Text = "[BTCUSDT](fg:cyan)"
-	pticker.SetRect(0, 0, 14, pheight)
-
-	pprice := widgets.NewParagraph()
-	pprice.Title = "Market price"
-	ppriceOffset := 14 + 14 + margin + 2
-	pprice.SetRect(14+margin, 0, ppriceOffset, pheight)
-
-	pfund := widgets.NewParagraph()
-	pfund.Title = "Funding rate"
-	pfund.SetRect(ppriceOffset+margin, 0, ppriceOffset+margin+16, 3)
-
-	tob := widgets.NewTable()
-	out := make([][]string, 20)
-	for i := 0; i < 20; i++ {
-		out[i] = []string{"n/a", "n/a"}
-	}
-	tob.TextStyle = ui.NewStyle(ui.ColorWhite)
-	tob.SetRect(0, pheight+2, 30, 22+pheight+2)
-	tob.PaddingBottom = 0
-	tob.PaddingTop = 0
-	tob.RowSeparator = false
-	tob.TextAlignment = ui.AlignCenter
-	for isrunning {
-		var (
-			asks = ob.getAsks()
-			bids = ob.getBids()
-		)
-		if len(asks) >= 10 {
-			for i := 0; i < 10; i++ {
-				out[i] = []string{fmt.Sprintf("[%.2f](fg:red)", asks[i].Price), fmt.Sprintf("[%.2f](fg:cyan)", asks[i].Volume)}
-			}
-		}
-		if len(bids) >= 10 {
-			for i := 0; i < 10; i++ {
-				out[i+10] = []string{fmt.Sprintf("[%.2f](fg:green)", bids[i].Price), fmt.Sprintf("[%.2f](fg:cyan)", bids[i].Volume)}
-			}
-		}
-		tob.Rows = out
-
-		pprice.Text = getMarketPrice()
-		pfund.Text = fmt.Sprintf("[%s](fg:yellow)", fundingRate)
-		ui.Render(pticker, pprice, pfund, tob)
-		time.Sleep(time.Millisecond * 20)
-	}
-}
-
-// Function to get the market price with arrow indicator
-func getMarketPrice() string {
-	price := fmt.Sprintf("[%s %.2f](fg:green)", ARROW_UP, currMarkPrice)
-	if prevMarkPrice > currMarkPrice {
-		price = fmt.Sprintf("[%s %.2f](fg:red)", ARROW_DOWN, currMarkPrice)
-	}
-	return price
-}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui/v3"
+)
+
+// Command line flags, the endpoints default to the production API of the selected exchange
+// and can be overridden to point at another server
+var (
+	exchangeflag = flag.String("exchange", "binance", "exchange to connect to: binance, binance-spot or kraken")
+	wsendpoint   = flag.String("ws", "", "websocket stream endpoint")
+	restendpoint = flag.String("rest", "", "REST API endpoint used for depth snapshots")
+	symbolsflag  = flag.String("symbols", "", "comma separated list of symbols to subscribe to (default: the exchange's BTC perpetual)")
+	logflag      = flag.String("log", filepath.Join(os.TempDir(), "crypto-terminal-indicator.log"), "file the errors are logged to while the UI is running")
+	depthflag    = flag.Int("depth", 10, "number of ask and bid levels shown in the orderbook table")
+	recordflag   = flag.String("record", "", "record every frame of the stream to this session file")
+	replayflag   = flag.String("replay", "", "replay a recorded session file instead of connecting to the exchange")
+	speedflag    = flag.String("speed", "1x", "replay speed, e.g. 4x, 0.5x or max")
+	staleflag    = flag.Duration("stale", 5*time.Second, "mark the feed as stale when no message arrived for this long")
+	refreshflag  = flag.Duration("refresh", 100*time.Millisecond, "how often the screen is redrawn when the market changed")
+	headlessflag = flag.Bool("headless", false, "run without the terminal UI and serve the markets over HTTP")
+	listenflag   = flag.String("listen", ":9090", "address the headless mode serves /metrics and /book on")
+	windowsflag  = flag.String("windows", "1m,5m,15m", "comma separated windows the VWAP and volume delta are computed over")
+	whaleflag    = flag.Float64("whale", 5, "highlight book levels with more than this many times the median level volume, 0 disables it")
+	paperflag    = flag.String("paper", "", "enable paper trading and keep the account in this file")
+	papersize    = flag.Float64("paper-size", 0.001, "quantity of the paper orders, changed with + and -")
+	compareflag  = flag.String("compare", "", "comma separated venues as exchange[:symbol][@market], e.g. binance-spot:ethusdt@ethusdt, each compared with one of the symbols (default: the first)")
+	alertsflag   = flag.String("alerts", "", "YAML or JSON file with alert rules on the mark price, funding rate and orderbook")
+	configflag   = flag.String("config", "", "YAML or JSON configuration file, the flags given on the command line override it")
+	themeflag    = flag.String("theme", "default", "color theme: default, colorblind or monochrome")
+	hideflag     = flag.String("hide", "", "comma separated panels to hide: index, funding, book, trades, flow, chart, venues, liquidations or oi")
+	liqflag      = flag.Float64("large-liquidation", 100000, "highlight liquidations worth more than this in the quote currency, 0 disables it")
+	oiflag       = flag.Duration("oi-interval", 10*time.Second, "how often the open interest is polled on the exchanges which don't stream it")
+)
+
+// Global variables
+var (
+	WIDTH      = 0
+	HEIGHT     = 0
+	ARROW_UP   = "↑"
+	ARROW_DOWN = "↓"
+)
+
+func main() {
+	flag.Parse()
+
+	// The configuration file only fills in the flags which weren't given on the command line
+	cfg := &config{}
+	if *configflag != "" {
+		var err error
+		if cfg, err = loadConfig(*configflag); err != nil {
+			log.Fatal(err)
+		}
+		if err := cfg.apply(flag.CommandLine); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// When replaying, the exchange and the symbols come from the session
+	var replay *session
+	if *replayflag != "" {
+		file, err := os.Open(*replayflag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		if replay, err = openSession(file); err != nil {
+			log.Fatal(err)
+		}
+		*exchangeflag = replay.header.Exchange
+		*symbolsflag = strings.Join(replay.header.Symbols, ",")
+	}
+	speed, err := parseSpeed(*speedflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	info, err := lookupExchange(*exchangeflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *symbolsflag == "" {
+		*symbolsflag = info.symbols
+	}
+	symbols := parseSymbols(*symbolsflag)
+	if len(symbols) == 0 {
+		log.Fatal("no symbols given")
+	}
+	depth := *depthflag
+	if depth < 1 {
+		log.Fatal("depth must be at least 1")
+	}
+	windows, err := parseWindows(*windowsflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var paper *paperAccount
+	if *paperflag != "" {
+		if *papersize <= 0 {
+			log.Fatal("paper-size must be positive")
+		}
+		if paper, err = loadPaperAccount(*paperflag); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *refreshflag <= 0 {
+		log.Fatal("refresh must be positive")
+	}
+	if *oiflag <= 0 {
+		log.Fatal("oi-interval must be positive")
+	}
+	// The theme has to be set before the widgets are created, as some of them take their colors at creation
+	if theme, err = lookupTheme(*themeflag, cfg.Colors); err != nil {
+		log.Fatal(err)
+	}
+	hidden, err := parseHidden(*hideflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var alertcfg *alertConfig
+	if *alertsflag != "" {
+		if alertcfg, err = loadAlertConfig(*alertsflag); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// The terminal belongs to the UI, so errors like malformed messages are logged to a file
+	logfile, err := os.OpenFile(*logflag, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer logfile.Close()
+
+	var (
+		client  = &http.Client{Timeout: 10 * time.Second}
+		ex      = info.new(*wsendpoint, *restendpoint, client)
+		ticks   map[string]Decimal
+		markets = make(map[string]*market, len(symbols)) // one market, and so one orderbook, per symbol
+	)
+	// the open interest is polled from the exchange itself, as the polled values aren't recorded
+	oi, _ := ex.(OpenInterestFetcher)
+	switch {
+	case replay != nil:
+		ex = replay.exchange(ex)
+		ticks = replay.header.TickSizes
+	case *recordflag != "":
+		ticks = fetchTickSizes(ex, symbols)
+		file, err := os.Create(*recordflag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		rec, err := newRecorder(file, sessionHeader{Exchange: *exchangeflag, Symbols: symbols, TickSizes: ticks})
+		if err != nil {
+			log.Fatal(err)
+		}
+		ex = newRecordingExchange(ex, rec)
+	default:
+		ticks = fetchTickSizes(ex, symbols)
+	}
+	// Every orderbook is seeded from a snapshot and kept in sync with the diffs from the stream
+	for _, s := range symbols {
+		tick := ticks[s]
+		if tick.Sign() <= 0 {
+			tick = defaultTickSize
+		}
+		markets[s] = newMarket(s, ex, depth, tick, windows)
+		if paper != nil {
+			// a market order walks the book beyond the levels which are shown
+			markets[s].rawDepth = paperDepth
+		}
+	}
+
+	// The feed establishes a WebSocket connection to the exchange using the github.com/gorilla/websocket
+	// and reconnects whenever it drops. All the symbols are subscribed over a single connection.
+	// During a replay the recorded frames go through the same feed without any network.
+	f := newFeed(ex, symbols, markets, *staleflag)
+	if alertcfg != nil {
+		f.alerts = newAlertEngine(alertcfg, client)
+	}
+	if replay != nil {
+		go f.runReplay(replay, speed)
+	} else {
+		go f.run()
+		if info.perpetual && oi != nil {
+			go f.pollOpenInterest(oi, *oiflag)
+		}
+	}
+
+	// Every compared venue has its own feed, they are only compared with live markets
+	var venues []*venue
+	if *compareflag != "" {
+		if replay != nil {
+			log.Fatal("compare can't be used while replaying a session")
+		}
+		if venues, err = parseVenues(*compareflag, symbols, client, depth, windows, *staleflag); err != nil {
+			log.Fatal(err)
+		}
+		for _, v := range venues {
+			go v.feed.run()
+		}
+	}
+
+	// Without a terminal the errors are logged to stderr and the markets are served over HTTP instead
+	if *headlessflag {
+		if err := newServer(ex.Name(), symbols, markets, f).serve(*listenflag); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := ui.Init(); err != nil {
+		log.Fatal(err)
+	}
+	defer ui.Close()
+	log.SetOutput(logfile)
+
+	isrunning := true
+	paused := false
+
+	// `selected` is the index of the symbol shown by the widgets, it is switched with <Tab> or the number keys
+	selected := 0
+	events := ui.PollEvents()
+
+	// The levels are grouped by `bookGroupings[grouping]` ticks, it is switched with the `g` key
+	grouping := 0
+	// The chart shows the candles of `candleIntervals[interval]`, it is switched with the `i` key
+	interval := 0
+
+	d := newDashboard(ex.Name(), f.alerts != nil, len(windows), paper != nil, mostCompared(venues), info.perpetual, cfg.Layout, hidden)
+	// `size` is the quantity of the paper orders, it is changed in steps of `--paper-size` with + and -
+	size := *papersize
+	WIDTH, HEIGHT = ui.TerminalDimensions()
+	d.layout(WIDTH, HEIGHT, depth)
+
+	// A new alert rings the terminal bell and blinks in the alerts panel for `flashFor`
+	flashFor := 5 * time.Second
+	var (
+		seenAlerts int
+		flashUntil time.Time
+	)
+
+	// The screen is redrawn on every tick of the refresh rate, but only when something changed
+	// since the last frame, and right away after a key press or a resize
+	ticker := time.NewTicker(*refreshflag)
+	defer ticker.Stop()
+	var (
+		last      *marketSnapshot
+		lastFrame string
+		dirty     = true
+	)
+	for isrunning {
+		select {
+		case e := <-events:
+			// q or Ctrl-C quits, p or space pauses the display, i switches the candle interval,
+			// g switches the grouping of the orderbook and <Tab> or 1-9 switch the symbol.
+			// With paper trading b, s, B, S and c place and cancel orders and + and - change their size.
+			switch e.ID {
+			case "q", "<C-c>":
+				isrunning = false
+				continue
+			case "p", "<Space>":
+				paused = !paused
+			case "i":
+				interval = (interval + 1) % len(candleIntervals)
+			case "g":
+				grouping = (grouping + 1) % len(bookGroupings)
+				for _, m := range markets {
+					m.setGrouping(bookGroupings[grouping])
+				}
+			case "<Resize>":
+				resize := e.Payload.(ui.Resize)
+				WIDTH, HEIGHT = resize.Width, resize.Height
+				d.layout(WIDTH, HEIGHT, depth)
+			case "+", "=":
+				size += *papersize
+			case "-":
+				// the size never drops below one step, the half step absorbs the rounding of the floats
+				if size-*papersize >= *papersize/2 {
+					size -= *papersize
+				}
+			default:
+				if paper != nil && paper.handleKey(markets[symbols[selected]].load(), e.ID, size) {
+					savePaperAccount(paper)
+				}
+			}
+			if next, ok := switchSymbol(e, selected, len(symbols)); ok {
+				selected = next
+			}
+			dirty = true
+		case <-ticker.C:
+		}
+
+		// The resting paper orders are matched against every market, not only the one shown
+		if paper != nil {
+			matched := false
+			for _, s := range symbols {
+				if paper.match(markets[s].load()) {
+					matched = true
+				}
+			}
+			if matched {
+				savePaperAccount(paper)
+			}
+		}
+
+		status := getFeedStatus(f)
+		if paused {
+			status = fmt.Sprintf("[PAUSED](%s,mod:bold) %s", theme.Warn, status)
+		}
+		alertText := ""
+		if f.alerts != nil {
+			alerts, fired := f.alerts.recent()
+			if fired > seenAlerts {
+				seenAlerts = fired
+				flashUntil = time.Now().Add(flashFor)
+				fmt.Fprint(os.Stdout, "\a")
+			}
+			flash := time.Now().Before(flashUntil) && time.Now().UnixMilli()/500%2 == 0
+			alertText = getAlertText(alerts, flash)
+		}
+
+		// While paused the widgets keep showing the last snapshot, the feed goes on in the background
+		m := last
+		if !paused || m == nil {
+			m = markets[symbols[selected]].load()
+		}
+		// the funding countdown ticks every second even when the market doesn't change
+		funding := getFundingText(m, time.Now())
+		paperText := ""
+		if paper != nil {
+			paperText = getPaperText(paper, m, size)
+		}
+		// the other venues change independently of the selected market
+		var venueRows [][]string
+		if d.pvenues != nil {
+			venueRows = getVenueRows(compareVenues(ex.Name(), m, venues))
+		}
+		frame := status + alertText + funding + paperText + fmt.Sprint(venueRows)
+		if !dirty && m == last && frame == lastFrame {
+			continue
+		}
+		last, lastFrame, dirty = m, frame, false
+
+		v := buildView(m, d.viewOptions(*whaleflag, *liqflag, candleIntervals[interval], time.Now()))
+		v.Status = status
+		v.Alerts = alertText
+		v.Paper = paperText
+		v.Venues = venueRows
+		d.apply(v)
+		d.render()
+	}
+}
+
+// Function to save the paper trading account, a failure is logged and shown in the paper trading panel
+func savePaperAccount(a *paperAccount) {
+	if err := a.save(); err != nil {
+		log.Printf("saving paper account: %v", err)
+		a.Message = fmt.Sprintf("saving failed: %v", err)
+	}
+}
+
+// Function to get the tick size of every symbol from the exchange, when it publishes them.
+// Symbols whose tick size isn't known are displayed with `defaultTickSize`.
+func fetchTickSizes(ex Exchange, symbols []string) map[string]Decimal {
+	info, ok := ex.(InstrumentInfo)
+	if !ok {
+		return nil
+	}
+	ticks, err := info.TickSizes(symbols)
+	if err != nil {
+		log.Printf("%s: fetching tick sizes: %v", ex.Name(), err)
+	}
+	return ticks
+}
+
+// Function to get the state of the feed, highlighting when it is stale or reconnecting
+func getFeedStatus(f *feed) string {
+	if f.replayed.Load() {
+		return fmt.Sprintf("[REPLAY FINISHED](%s)", theme.Accent)
+	}
+	if f.replaying.Load() && !f.stale() {
+		return fmt.Sprintf("[REPLAY](%s)", theme.Accent)
+	}
+	if f.stale() {
+		return fmt.Sprintf("[STALE / reconnecting](%s,mod:bold)", theme.Down)
+	}
+	return fmt.Sprintf("[LIVE](%s)", theme.Up)
+}
+
+// Function to get the symbol index selected by a keyboard event.
+// <Tab> cycles through the symbols and the keys 1-9 jump directly to a symbol.
+func switchSymbol(e ui.Event, selected, count int) (int, bool) {
+	if e.Type != ui.KeyboardEvent {
+		return selected, false
+	}
+	if e.ID == "<Tab>" {
+		return (selected + 1) % count, true
+	}
+	if len(e.ID) == 1 && e.ID[0] >= '1' && e.ID[0] <= '9' {
+		if i := int(e.ID[0] - '1'); i < count {
+			return i, true
+		}
+	}
+	return selected, false
+}
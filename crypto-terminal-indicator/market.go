@@ -0,0 +1,274 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Tick size used when the exchange doesn't publish the one of a symbol
+var defaultTickSize = NewDecimal(1000000) // 0.01
+
+// Number of recent liquidations kept per market for the liquidations panel
+const maxLiquidations = 64
+
+// The open interest is sampled at most once per `openInterestSpacing`, the last `maxOpenInterestSamples`
+// samples are kept, which is an hour
+const (
+	openInterestSpacing    = 10 * time.Second
+	maxOpenInterestSamples = 360
+)
+
+// Structure representing the open interest of a market at one point in time,
+// together with the mark price at that time so that both can be plotted against each other
+type openInterestSample struct {
+	Time         time.Time
+	OpenInterest float64
+	MarkPrice    float64 // zero when no mark price was received yet
+}
+
+// Structure holding everything that is tracked for a single symbol.
+// The orderbook and prices are only touched by the feed goroutine, which publishes an immutable
+// `marketSnapshot` after every update for the render loop to read.
+type market struct {
+	Symbol        string // upper case symbol as shown in the UI, e.g. BTCUSDT
+	ob            *Orderbook
+	depthsync     *depthSync
+	currMarkPrice float64
+	prevMarkPrice float64
+	indexPrice    float64
+	fundingRate   string
+	depth         int     // number of levels per side included in the snapshots
+	rawDepth      int     // number of ungrouped levels per side included in the snapshots, when more than `depth`
+	tickSize      Decimal // prices are displayed with as many decimal places as the tick size
+	trades        *tradeRing
+	recentTrades  []TradeEvent // trades of the last published snapshot, nil when new trades arrived since
+	candles       candleBuilder
+	recentCandles []Candle // candles of the last published snapshot, nil when new trades arrived since
+
+	// funding is paid every `fundingInterval`, the next time at `nextFundingTime`
+	fundingInterval time.Duration
+	nextFundingTime time.Time
+
+	// The order flow is computed over each of the `windows` from the trades in `flow`
+	windows    []time.Duration
+	flow       *tradeWindow
+	cumDelta   float64     // aggressive buy minus sell volume since the start
+	recentFlow []flowStats // order flow of the last published snapshot, nil when new trades arrived since
+
+	liquidations       []LiquidationEvent   // oldest first
+	recentLiquidations []LiquidationEvent   // liquidations of the last published snapshot, nil when new ones arrived since
+	openInterest       []openInterestSample // oldest first
+	recentOpenInterest []openInterestSample // samples of the last published snapshot, nil when new ones arrived since
+
+	// Number of ticks the levels of the snapshots are grouped into buckets of, zero shows every level.
+	// It is written by the render loop, so it is the only field which is shared between the goroutines.
+	grouping atomic.Int64
+
+	snapshot atomic.Pointer[marketSnapshot]
+}
+
+// Structure representing the state of a market at one point in time.
+// A snapshot is never modified after it has been published, so it can be shared between goroutines.
+type marketSnapshot struct {
+	Symbol        string
+	TickSize      Decimal
+	Bucket        Decimal          // price bucket the levels are grouped into, zero when they aren't grouped
+	Asks          []OrderbookEntry // best asks, lowest price first
+	Bids          []OrderbookEntry // best bids, highest price first
+	BestAsk       OrderbookEntry   // best ask before grouping, zero when there are no asks
+	BestBid       OrderbookEntry   // best bid before grouping, zero when there are no bids
+	CurrMarkPrice float64
+	PrevMarkPrice float64
+	IndexPrice    float64 // zero when the exchange doesn't publish it
+	FundingRate   string
+	Trades        []TradeEvent // recent trades, newest first
+	Candles       []Candle     // one minute candles built from the trades, oldest first
+
+	FundingInterval time.Duration
+	NextFundingTime time.Time // zero when the exchange doesn't publish it
+
+	Flow     []flowStats // order flow of each window, shortest first
+	CumDelta float64
+
+	// The levels before grouping, the same as `Asks` and `Bids` while the levels aren't grouped
+	// unless the market keeps a deeper `rawDepth` of them, as it does for the paper fills
+	RawAsks []OrderbookEntry
+	RawBids []OrderbookEntry
+
+	Liquidations []LiquidationEvent   // recent liquidations, newest first
+	OpenInterest []openInterestSample // open interest samples, oldest first
+}
+
+// `newMarket()` is a constructor function for creating a new instance of `market` struct
+// whose orderbook is seeded from snapshots fetched from the exchange, when it supports that.
+// The order flow is computed over each of the `windows`, they are expected in ascending order.
+func newMarket(symbol string, ex Exchange, depth int, tickSize Decimal, windows []time.Duration) *market {
+	var span time.Duration
+	if n := len(windows); n > 0 {
+		span = windows[n-1]
+	}
+	m := &market{
+		Symbol:      strings.ToUpper(symbol),
+		ob:          NewOrderbook(),
+		fundingRate: "n/a",
+		depth:       depth,
+		tickSize:    tickSize,
+		trades:      newTradeRing(maxTrades),
+		windows:     windows,
+		flow:        newTradeWindow(span),
+	}
+	var fetch func() (*DepthEvent, error)
+	if fetcher, ok := ex.(SnapshotFetcher); ok {
+		fetch = func() (*DepthEvent, error) {
+			return fetcher.FetchSnapshot(symbol)
+		}
+	}
+	m.depthsync = newDepthSync(m.ob, fetch)
+	m.publish()
+	return m
+}
+
+// Function to add a trade to the time & sales of the market
+func (m *market) addTrade(t TradeEvent) {
+	m.trades.push(t)
+	m.recentTrades = nil
+	m.candles.add(t)
+	m.recentCandles = nil
+	m.flow.push(t)
+	m.cumDelta += tradeDelta(t)
+	m.recentFlow = nil
+}
+
+// Function to add a liquidation to the liquidations of the market
+func (m *market) addLiquidation(l LiquidationEvent) {
+	m.liquidations = append(m.liquidations, l)
+	if len(m.liquidations) > maxLiquidations {
+		m.liquidations = append(m.liquidations[:0], m.liquidations[len(m.liquidations)-maxLiquidations:]...)
+	}
+	m.recentLiquidations = nil
+}
+
+// Function to sample the open interest of the market together with the mark price.
+// A value arriving within `openInterestSpacing` of the last sample replaces it rather than adding a new one.
+func (m *market) addOpenInterest(ev OpenInterestEvent) {
+	sample := openInterestSample{Time: ev.Time, OpenInterest: ev.OpenInterest, MarkPrice: m.currMarkPrice}
+	if n := len(m.openInterest); n > 0 && sample.Time.Sub(m.openInterest[n-1].Time) < openInterestSpacing {
+		sample.Time = m.openInterest[n-1].Time
+		m.openInterest[n-1] = sample
+	} else {
+		m.openInterest = append(m.openInterest, sample)
+	}
+	if len(m.openInterest) > maxOpenInterestSamples {
+		m.openInterest = append(m.openInterest[:0], m.openInterest[len(m.openInterest)-maxOpenInterestSamples:]...)
+	}
+	m.recentOpenInterest = nil
+}
+
+// Function to publish the current state of the market, it must only be called from the feed goroutine.
+// The trades and candles are only copied when they changed, otherwise the slices of the previous snapshot are shared.
+func (m *market) publish() {
+	if m.recentTrades == nil {
+		m.recentTrades = m.trades.recent()
+	}
+	if m.recentCandles == nil {
+		m.recentCandles = m.candles.snapshot()
+	}
+	if m.recentLiquidations == nil {
+		m.recentLiquidations = make([]LiquidationEvent, len(m.liquidations))
+		for i, l := range m.liquidations {
+			m.recentLiquidations[len(m.liquidations)-1-i] = l
+		}
+	}
+	if m.recentOpenInterest == nil {
+		m.recentOpenInterest = append([]openInterestSample{}, m.openInterest...)
+	}
+	// The windows end at the latest trade rather than the wall clock, so replays give the same figures
+	if m.recentFlow == nil {
+		m.recentFlow = make([]flowStats, len(m.windows))
+		trades := m.flow.all()
+		var now time.Time
+		if len(trades) > 0 {
+			now = trades[len(trades)-1].Time
+		}
+		for i, w := range m.windows {
+			m.recentFlow[i] = computeFlow(trades, now, w)
+		}
+	}
+	var bestAsk, bestBid OrderbookEntry
+	if best, ok := m.ob.Asks.Min(); ok {
+		bestAsk = best
+	}
+	if best, ok := m.ob.Bids.Min(); ok {
+		bestBid = best
+	}
+	bucket := m.tickSize.Mul(m.grouping.Load())
+	rawDepth := m.depth
+	if m.rawDepth > rawDepth {
+		rawDepth = m.rawDepth
+	}
+	rawAsks, rawBids := m.ob.getAsks(rawDepth), m.ob.getBids(rawDepth)
+	asks, bids := topEntries(rawAsks, m.depth), topEntries(rawBids, m.depth)
+	if bucket.Sign() > 0 {
+		asks = m.ob.getGroupedAsks(m.depth, bucket)
+		bids = m.ob.getGroupedBids(m.depth, bucket)
+	}
+	m.snapshot.Store(&marketSnapshot{
+		Symbol:        m.Symbol,
+		TickSize:      m.tickSize,
+		Bucket:        bucket,
+		Asks:          asks,
+		Bids:          bids,
+		BestAsk:       bestAsk,
+		BestBid:       bestBid,
+		CurrMarkPrice: m.currMarkPrice,
+		PrevMarkPrice: m.prevMarkPrice,
+		IndexPrice:    m.indexPrice,
+		FundingRate:   m.fundingRate,
+		Trades:        m.recentTrades,
+		Candles:       m.recentCandles,
+
+		FundingInterval: m.fundingInterval,
+		NextFundingTime: m.nextFundingTime,
+
+		Flow:     m.recentFlow,
+		CumDelta: m.cumDelta,
+
+		RawAsks: rawAsks,
+		RawBids: rawBids,
+
+		Liquidations: m.recentLiquidations,
+		OpenInterest: m.recentOpenInterest,
+	})
+}
+
+// Function to group the levels of the following snapshots into price buckets of `multiple` ticks.
+// It is safe to call from any goroutine.
+func (m *market) setGrouping(multiple int64) {
+	m.grouping.Store(multiple)
+}
+
+// Function to get the latest published snapshot of the market, it is safe to call from any goroutine
+func (m *market) load() *marketSnapshot {
+	return m.snapshot.Load()
+}
+
+// Function to get the first `n` of the levels, they are shared with the slice
+func topEntries(levels []OrderbookEntry, n int) []OrderbookEntry {
+	if len(levels) > n {
+		return levels[:n:n]
+	}
+	return levels
+}
+
+// Function to split the comma separated `--symbols` flag into a list of lower case symbols
+func parseSymbols(list string) []string {
+	var symbols []string
+	for _, s := range strings.Split(list, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s != "" {
+			symbols = append(symbols, s)
+		}
+	}
+	return symbols
+}
@@ -0,0 +1,137 @@
+package main
+
+import "github.com/google/btree"
+
+// Degree of the B-trees holding the price levels
+const orderbookDegree = 32
+
+// Structure representing an orderbook entry
+// Prices and volumes are exact decimals, so every price maps to exactly one level.
+type OrderbookEntry struct {
+	Price  Decimal `json:"price"`
+	Volume Decimal `json:"volume"`
+}
+
+// Ordering of the asks, the lowest price is the best ask
+func byBestAsk(a, b OrderbookEntry) bool { return a.Price.Cmp(b.Price) < 0 }
+
+// Ordering of the bids, the highest price is the best bid
+func byBestBid(a, b OrderbookEntry) bool { return a.Price.Cmp(b.Price) > 0 }
+
+// Structure representing the orderbook
+// Both sides are B-trees keyed by price and ordered best level first, so the top levels
+// can be read without sorting the whole book.
+type Orderbook struct {
+	Asks *btree.BTreeG[OrderbookEntry]
+	Bids *btree.BTreeG[OrderbookEntry]
+}
+
+// `NewOrderbook()` is a constructor function for creating a new insatnce of `Orderbook` struct
+func NewOrderbook() *Orderbook {
+	return &Orderbook{
+		Asks: btree.NewG(orderbookDegree, byBestAsk),
+		Bids: btree.NewG(orderbookDegree, byBestBid),
+	}
+}
+
+// Function to clear all the levels of the orderbook
+func (ob *Orderbook) reset() {
+	ob.Asks.Clear(false)
+	ob.Bids.Clear(false)
+}
+
+// Function to handle depth response received from the exchange so as to maintain the orderbook
+// It takes two slices, asks and bids, as input. It iterates over the asks and bids slices
+// and calls the addAsk() and addBid() methods to update the order book accordingly.
+func (ob *Orderbook) handleDepthResponse(asks, bids []OrderbookEntry) {
+	for _, ask := range asks {
+		ob.addAsk(ask.Price, ask.Volume)
+	}
+	for _, bid := range bids {
+		ob.addBid(bid.Price, bid.Volume)
+	}
+}
+
+// Function to add a bid to the orderbook
+// If the volume is zero, the level is deleted from the tree.
+// Otherwise, it adds or updates the volume for the given price.
+func (ob *Orderbook) addBid(price, volume Decimal) {
+	setLevel(ob.Bids, price, volume)
+}
+
+// Function to add an ask to the orderbook
+func (ob *Orderbook) addAsk(price, volume Decimal) {
+	setLevel(ob.Asks, price, volume)
+}
+
+// Function to set the volume of a price level on one side of the book
+func setLevel(side *btree.BTreeG[OrderbookEntry], price, volume Decimal) {
+	if volume.IsZero() {
+		side.Delete(OrderbookEntry{Price: price})
+		return
+	}
+	side.ReplaceOrInsert(OrderbookEntry{Price: price, Volume: volume})
+}
+
+// The two methods `getBids()` and `getAsks()`, Function to get the best `depth` bids and asks entries from the orderbook
+func (ob *Orderbook) getBids(depth int) []OrderbookEntry {
+	return topLevels(ob.Bids, depth)
+}
+
+// Function to get the best asks from the orderbook
+func (ob *Orderbook) getAsks(depth int) []OrderbookEntry {
+	return topLevels(ob.Asks, depth)
+}
+
+// Function to walk one side of the book from the best level and collect at most `depth` levels
+func topLevels(side *btree.BTreeG[OrderbookEntry], depth int) []OrderbookEntry {
+	if depth > side.Len() {
+		depth = side.Len()
+	}
+	entries := make([]OrderbookEntry, 0, depth)
+	side.Ascend(func(e OrderbookEntry) bool {
+		if len(entries) == depth {
+			return false
+		}
+		entries = append(entries, e)
+		return true
+	})
+	return entries
+}
+
+// Function to get the best `depth` asks with the levels merged into price buckets of size `bucket`.
+// Asks are rounded up to their bucket, so a bucket never shows a better price than its levels.
+func (ob *Orderbook) getGroupedAsks(depth int, bucket Decimal) []OrderbookEntry {
+	return topBuckets(ob.Asks, depth, bucket, true)
+}
+
+// Function to get the best `depth` bids with the levels merged into price buckets of size `bucket`.
+// Bids are rounded down to their bucket.
+func (ob *Orderbook) getGroupedBids(depth int, bucket Decimal) []OrderbookEntry {
+	return topBuckets(ob.Bids, depth, bucket, false)
+}
+
+// Function to walk one side of the book from the best level and merge the levels into buckets,
+// collecting at most `depth` buckets
+func topBuckets(side *btree.BTreeG[OrderbookEntry], depth int, bucket Decimal, roundUp bool) []OrderbookEntry {
+	if bucket.Sign() <= 0 {
+		return topLevels(side, depth)
+	}
+	entries := make([]OrderbookEntry, 0, depth)
+	side.Ascend(func(e OrderbookEntry) bool {
+		price := e.Price.Sub(e.Price.Mod(bucket))
+		if roundUp && price != e.Price {
+			price = price.Add(bucket)
+		}
+		if n := len(entries); n > 0 && entries[n-1].Price == price {
+			entries[n-1].Volume = entries[n-1].Volume.Add(e.Volume)
+			return true
+		}
+		if len(entries) == depth {
+			return false
+		}
+		entries = append(entries, OrderbookEntry{Price: price, Volume: e.Volume})
+		return true
+	})
+	return entries
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Structure representing the orderbook as it was before the B-trees, a map per side sorted on every read.
+// It is only kept to benchmark the B-trees against.
+type mapOrderbook struct {
+	Asks map[Decimal]Decimal
+	Bids map[Decimal]Decimal
+}
+
+func (ob *mapOrderbook) setLevel(side map[Decimal]Decimal, price, volume Decimal) {
+	if volume.IsZero() {
+		delete(side, price)
+		return
+	}
+	side[price] = volume
+}
+
+// Function to sort one side of the book and keep its best `depth` levels
+func (ob *mapOrderbook) topLevels(side map[Decimal]Decimal, depth int, better func(a, b Decimal) bool) []OrderbookEntry {
+	entries := make([]OrderbookEntry, 0, len(side))
+	for price, volume := range side {
+		entries = append(entries, OrderbookEntry{Price: price, Volume: volume})
+	}
+	sort.Slice(entries, func(i, j int) bool { return better(entries[i].Price, entries[j].Price) })
+	if len(entries) > depth {
+		entries = entries[:depth]
+	}
+	return entries
+}
+
+// Function to fill both kinds of books with `levels` asks and bids around a price of 27000
+func benchmarkBooks(levels int) (*Orderbook, *mapOrderbook) {
+	ob := NewOrderbook()
+	mb := &mapOrderbook{Asks: make(map[Decimal]Decimal), Bids: make(map[Decimal]Decimal)}
+	mid, tick := NewDecimal(2700000000000), NewDecimal(10000000) // 27000 and 0.1
+	for i := 0; i < levels; i++ {
+		ask := mid.Add(tick.Mul(int64(i + 1)))
+		bid := mid.Sub(tick.Mul(int64(i)))
+		volume := NewDecimal(int64(100000000 + i%97*1000000))
+		ob.addAsk(ask, volume)
+		ob.addBid(bid, volume)
+		mb.setLevel(mb.Asks, ask, volume)
+		mb.setLevel(mb.Bids, bid, volume)
+	}
+	return ob, mb
+}
+
+func TestTopLevelsMatchSortedMap(t *testing.T) {
+	ob, mb := benchmarkBooks(500)
+	asks := mb.topLevels(mb.Asks, 20, func(a, b Decimal) bool { return a.Cmp(b) < 0 })
+	bids := mb.topLevels(mb.Bids, 20, func(a, b Decimal) bool { return a.Cmp(b) > 0 })
+	if got, want := fmt.Sprint(ob.getAsks(20)), fmt.Sprint(asks); got != want {
+		t.Errorf("asks = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(ob.getBids(20)), fmt.Sprint(bids); got != want {
+		t.Errorf("bids = %s, want %s", got, want)
+	}
+}
+
+func TestGroupedLevels(t *testing.T) {
+	ob := NewOrderbook()
+	ob.handleDepthResponse(
+		priceLevels(t, "100:1", "100.3:2", "100.5:1", "100.51:4", "101:1", "102.2:3"),
+		priceLevels(t, "100:1", "99.9:2", "99.5:1", "99.49:4", "99:1", "97.8:3"),
+	)
+	for _, c := range []struct {
+		name       string
+		depth      int
+		bucket     string
+		asks, bids []OrderbookEntry
+	}{
+		// the prices on the edge of a bucket stay in it, the asks above it are rounded up and the bids below it down
+		{"half", 10, "0.5",
+			priceLevels(t, "100:1", "100.5:3", "101:5", "102.5:3"),
+			priceLevels(t, "100:1", "99.5:3", "99:5", "97.5:3")},
+		// the depth counts the buckets, the last one still holds every level rounded into it
+		{"depth of buckets", 2, "0.5",
+			priceLevels(t, "100:1", "100.5:3"),
+			priceLevels(t, "100:1", "99.5:3")},
+		{"whole", 10, "1",
+			priceLevels(t, "100:1", "101:8", "103:3"),
+			priceLevels(t, "100:1", "99:8", "97:3")},
+		// a bucket wider than the whole book merges every level past the best one
+		{"wide", 10, "5",
+			priceLevels(t, "100:1", "105:11"),
+			priceLevels(t, "100:1", "95:11")},
+		{"single bucket", 1, "5",
+			priceLevels(t, "100:1"),
+			priceLevels(t, "100:1")},
+		{"not grouped", 3, "0",
+			priceLevels(t, "100:1", "100.3:2", "100.5:1"),
+			priceLevels(t, "100:1", "99.9:2", "99.5:1")},
+		{"no depth", 0, "0.5", priceLevels(t), priceLevels(t)},
+	} {
+		bucket := dec(t, c.bucket)
+		if got := ob.getGroupedAsks(c.depth, bucket); !reflect.DeepEqual(got, c.asks) {
+			t.Errorf("%s: asks = %s, want %s", c.name, fmt.Sprint(got), fmt.Sprint(c.asks))
+		}
+		if got := ob.getGroupedBids(c.depth, bucket); !reflect.DeepEqual(got, c.bids) {
+			t.Errorf("%s: bids = %s, want %s", c.name, fmt.Sprint(got), fmt.Sprint(c.bids))
+		}
+	}
+}
+
+// Reading the top 20 levels of a side of 5000 levels, as every published frame does
+func BenchmarkTopLevels(b *testing.B) {
+	const levels, depth = 5000, 20
+	ob, mb := benchmarkBooks(levels)
+	b.Run("btree", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ob.getAsks(depth)
+			ob.getBids(depth)
+		}
+	})
+	b.Run("map-sort", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			mb.topLevels(mb.Asks, depth, func(a, b Decimal) bool { return a.Cmp(b) < 0 })
+			mb.topLevels(mb.Bids, depth, func(a, b Decimal) bool { return a.Cmp(b) > 0 })
+		}
+	})
+}
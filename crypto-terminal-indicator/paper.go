@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Number of fills kept in the paper trading file
+const maxPaperFills = 100
+
+// Number of levels per side the markets publish for the paper orders to fill against, however few are shown
+const paperDepth = 1000
+
+// Sides of a paper order
+const (
+	sideBuy  = "buy"
+	sideSell = "sell"
+)
+
+// Structure representing a resting paper limit order
+type paperOrder struct {
+	ID       int64     `json:"id"`
+	Symbol   string    `json:"symbol"`
+	Side     string    `json:"side"`
+	Price    float64   `json:"price"`
+	Quantity float64   `json:"quantity"` // quantity left to fill
+	Placed   time.Time `json:"placed"`
+}
+
+// Structure representing the paper position in a symbol.
+// `Quantity` is positive when long and negative when short.
+type paperPosition struct {
+	Quantity    float64 `json:"quantity"`
+	AvgEntry    float64 `json:"avg_entry"`
+	RealizedPnL float64 `json:"realized_pnl"`
+}
+
+// Structure representing a fill of a paper order
+type paperFill struct {
+	OrderID  int64     `json:"order_id"`
+	Symbol   string    `json:"symbol"`
+	Side     string    `json:"side"`
+	Price    float64   `json:"price"`
+	Quantity float64   `json:"quantity"`
+	Time     time.Time `json:"time"`
+}
+
+// Structure representing the paper trading account, it is saved to `path` after every change.
+// Market orders are filled right away by walking the levels of the book, limit orders rest
+// until the book or a trade crosses their price. The account is only used by the render loop.
+type paperAccount struct {
+	path string
+
+	NextID    int64                     `json:"next_id"`
+	Positions map[string]*paperPosition `json:"positions"` // keyed by the upper case symbol
+	Orders    []paperOrder              `json:"orders"`
+	Fills     []paperFill               `json:"fills"` // newest last
+
+	Message string `json:"-"` // outcome of the last action, shown in the paper trading panel
+}
+
+// Error returned when an order finds no level to fill against
+var errNoLiquidity = errors.New("no liquidity in the book")
+
+// Function to load the paper trading account from a file, a missing file starts a new account
+func loadPaperAccount(path string) (*paperAccount, error) {
+	a := &paperAccount{path: path, NextID: 1, Positions: make(map[string]*paperPosition)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("paper account %s: %w", path, err)
+	}
+	if a.Positions == nil {
+		a.Positions = make(map[string]*paperPosition)
+	}
+	return a, nil
+}
+
+// Function to save the account, it is written to a temporary file first so a crash never leaves half a file
+func (a *paperAccount) save() error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(a.path), filepath.Base(a.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), a.path)
+}
+
+// Function to get the position in a symbol, a flat position when there is none
+func (a *paperAccount) position(symbol string) paperPosition {
+	if p, ok := a.Positions[symbol]; ok {
+		return *p
+	}
+	return paperPosition{}
+}
+
+// Function to get the resting orders of a symbol
+func (a *paperAccount) openOrders(symbol string) []paperOrder {
+	var orders []paperOrder
+	for _, o := range a.Orders {
+		if o.Symbol == symbol {
+			orders = append(orders, o)
+		}
+	}
+	return orders
+}
+
+// Function to send a market order, it takes the levels of the other side of the book from the best one
+// until the quantity is filled. What the `paperDepth` levels of the snapshot can't fill is cancelled.
+func (a *paperAccount) marketOrder(m *marketSnapshot, side string, quantity float64, now time.Time) error {
+	filled, price := walkBook(opposingLevels(m, side), side, quantity, 0)
+	if filled == 0 {
+		return errNoLiquidity
+	}
+	id := a.NextID
+	a.NextID++
+	a.fill(paperFill{OrderID: id, Symbol: m.Symbol, Side: side, Price: price, Quantity: filled, Time: now})
+	a.Message = fmt.Sprintf("%s %g %s at %.*f", side, filled, m.Symbol, m.TickSize.Places(), price)
+	if filled < quantity {
+		a.Message += fmt.Sprintf(", %g cancelled", quantity-filled)
+	}
+	return nil
+}
+
+// Function to place a limit order. The part crossing the book is filled right away,
+// up to the limit price, the rest rests until the market trades through it.
+func (a *paperAccount) limitOrder(m *marketSnapshot, side string, price, quantity float64, now time.Time) {
+	id := a.NextID
+	a.NextID++
+	places := m.TickSize.Places()
+	filled, avg := walkBook(opposingLevels(m, side), side, quantity, price)
+	if filled > 0 {
+		a.fill(paperFill{OrderID: id, Symbol: m.Symbol, Side: side, Price: avg, Quantity: filled, Time: now})
+	}
+	if rest := quantity - filled; rest > 0 {
+		a.Orders = append(a.Orders, paperOrder{ID: id, Symbol: m.Symbol, Side: side, Price: price, Quantity: rest, Placed: now})
+	}
+	a.Message = fmt.Sprintf("%s %g %s limit %.*f", side, quantity, m.Symbol, places, price)
+	if filled > 0 {
+		a.Message += fmt.Sprintf(", %g filled at %.*f", filled, places, avg)
+	}
+}
+
+// Function to cancel the resting orders of a symbol, it returns the number of cancelled orders
+func (a *paperAccount) cancelOrders(symbol string) int {
+	kept := a.Orders[:0]
+	for _, o := range a.Orders {
+		if o.Symbol != symbol {
+			kept = append(kept, o)
+		}
+	}
+	cancelled := len(a.Orders) - len(kept)
+	a.Orders = kept
+	a.Message = fmt.Sprintf("%d orders cancelled", cancelled)
+	return cancelled
+}
+
+// Function to fill the resting orders of a market which the market traded through.
+// A buy fills at its price once the best ask is at or below it, or a sell traded below it after the order
+// was placed, as the queue at its own price has to be worked through first. A sell fills the other way around.
+// It reports if anything was filled.
+func (a *paperAccount) match(m *marketSnapshot) bool {
+	matched := false
+	kept := a.Orders[:0]
+	for _, o := range a.Orders {
+		if o.Symbol != m.Symbol || !crossed(m, o) {
+			kept = append(kept, o)
+			continue
+		}
+		a.fill(paperFill{OrderID: o.ID, Symbol: o.Symbol, Side: o.Side, Price: o.Price, Quantity: o.Quantity, Time: time.Now()})
+		a.Message = fmt.Sprintf("%s %g %s filled at %.*f", o.Side, o.Quantity, o.Symbol, m.TickSize.Places(), o.Price)
+		matched = true
+	}
+	a.Orders = kept
+	return matched
+}
+
+// Function to check if the market crossed the price of a resting order
+func crossed(m *marketSnapshot, o paperOrder) bool {
+	if o.Side == sideBuy {
+		if m.BestAsk.Price.Sign() > 0 && m.BestAsk.Price.Float64() <= o.Price {
+			return true
+		}
+	} else if m.BestBid.Price.Sign() > 0 && m.BestBid.Price.Float64() >= o.Price {
+		return true
+	}
+	// The trades are newest first, only the ones printed after the order was placed count
+	for _, t := range m.Trades {
+		if t.Time.Before(o.Placed) {
+			break
+		}
+		if o.Side == sideBuy && t.BuyerMaker && t.Price.Float64() < o.Price {
+			return true
+		}
+		if o.Side == sideSell && !t.BuyerMaker && t.Price.Float64() > o.Price {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to apply a fill to the position of its symbol.
+// A fill in the direction of the position moves the average entry, a fill against it realizes the PnL
+// of the closed quantity and a fill larger than the position opens a new one at the fill price.
+func (a *paperAccount) fill(f paperFill) {
+	p, ok := a.Positions[f.Symbol]
+	if !ok {
+		p = &paperPosition{}
+		a.Positions[f.Symbol] = p
+	}
+	qty := f.Quantity
+	if f.Side == sideSell {
+		qty = -qty
+	}
+	switch {
+	case p.Quantity == 0 || (p.Quantity > 0) == (qty > 0):
+		total := math.Abs(p.Quantity) + math.Abs(qty)
+		p.AvgEntry = (p.AvgEntry*math.Abs(p.Quantity) + f.Price*math.Abs(qty)) / total
+		p.Quantity += qty
+	default:
+		closed := math.Min(math.Abs(qty), math.Abs(p.Quantity))
+		if p.Quantity > 0 {
+			p.RealizedPnL += closed * (f.Price - p.AvgEntry)
+		} else {
+			p.RealizedPnL += closed * (p.AvgEntry - f.Price)
+		}
+		p.Quantity += qty
+		switch {
+		case math.Abs(p.Quantity) < 1e-12:
+			p.Quantity = 0
+			p.AvgEntry = 0
+		case (p.Quantity > 0) == (qty > 0):
+			// the fill flipped the position
+			p.AvgEntry = f.Price
+		}
+	}
+
+	a.Fills = append(a.Fills, f)
+	if len(a.Fills) > maxPaperFills {
+		a.Fills = a.Fills[len(a.Fills)-maxPaperFills:]
+	}
+}
+
+// Function to get the unrealized PnL of a position marked to the given price
+func (p paperPosition) unrealizedPnL(mark float64) float64 {
+	if p.Quantity == 0 || mark <= 0 {
+		return 0
+	}
+	return p.Quantity * (mark - p.AvgEntry)
+}
+
+// Function to get the levels an order of the given side fills against, the asks for a buy and the bids for a sell
+func opposingLevels(m *marketSnapshot, side string) []OrderbookEntry {
+	if side == sideBuy {
+		return m.RawAsks
+	}
+	return m.RawBids
+}
+
+// Function to walk the levels from the best one, taking their volume until `quantity` is filled.
+// A non-zero `limit` stops the walk at the first level beyond it. It returns the filled quantity
+// and its average price, the slippage being the distance of that price from the best level.
+func walkBook(levels []OrderbookEntry, side string, quantity, limit float64) (float64, float64) {
+	var filled, notional float64
+	for _, l := range levels {
+		if filled >= quantity {
+			break
+		}
+		price := l.Price.Float64()
+		if limit > 0 && ((side == sideBuy && price > limit) || (side == sideSell && price < limit)) {
+			break
+		}
+		take := math.Min(l.Volume.Float64(), quantity-filled)
+		filled += take
+		notional += take * price
+	}
+	if filled == 0 {
+		return 0, 0
+	}
+	return filled, notional / filled
+}
+
+// Function to place the order of a key on the market, `b` and `s` send a market buy or sell of `size`,
+// `B` and `S` place a limit buy at the best bid or a limit sell at the best ask, and `c` cancels the
+// resting orders. It reports if the key was a paper trading key, the outcome is left in `Message`.
+func (a *paperAccount) handleKey(m *marketSnapshot, key string, size float64) bool {
+	now := time.Now()
+	if key == "c" {
+		a.cancelOrders(m.Symbol)
+		return true
+	}
+	side, limit, ok := paperSide(key)
+	if !ok {
+		return false
+	}
+	if !limit {
+		if err := a.marketOrder(m, side, size, now); err != nil {
+			a.Message = fmt.Sprintf("%s %g %s: %v", side, size, m.Symbol, err)
+		}
+		return true
+	}
+	best := m.BestBid
+	if side == sideSell {
+		best = m.BestAsk
+	}
+	if best.Price.Sign() <= 0 {
+		a.Message = fmt.Sprintf("%s %g %s: %v", side, size, m.Symbol, errNoLiquidity)
+		return true
+	}
+	a.limitOrder(m, side, best.Price.Float64(), size, now)
+	return true
+}
+
+// Function to parse the side of an order from a key, lower case keys send market orders
+// and upper case keys limit orders, `b` buys and `s` sells
+func paperSide(key string) (side string, limit bool, ok bool) {
+	switch key {
+	case "b", "B":
+		side = sideBuy
+	case "s", "S":
+		side = sideSell
+	default:
+		return "", false, false
+	}
+	return side, strings.ToUpper(key) == key, true
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function to build levels of the given prices and volumes, e.g. "100:1", "101:2.5"
+func priceLevels(t *testing.T, levels ...string) []OrderbookEntry {
+	entries := make([]OrderbookEntry, len(levels))
+	for i, l := range levels {
+		price, volume, _ := strings.Cut(l, ":")
+		entries[i] = OrderbookEntry{Price: dec(t, price), Volume: dec(t, volume)}
+	}
+	return entries
+}
+
+// Function to compare floats which went through a few multiplications
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestWalkBook(t *testing.T) {
+	asks := priceLevels(t, "100:1", "101:2", "103:5")
+	bids := priceLevels(t, "99:1", "98:2", "96:5")
+	for _, c := range []struct {
+		name     string
+		levels   []OrderbookEntry
+		side     string
+		quantity float64
+		limit    float64
+		filled   float64
+		avg      float64
+	}{
+		{"best level only", asks, sideBuy, 0.5, 0, 0.5, 100},
+		{"slips over two levels", asks, sideBuy, 2, 0, 2, 100.5},
+		{"takes every level", asks, sideBuy, 8, 0, 8, (100 + 202 + 515) / 8.0},
+		{"more than the book", asks, sideBuy, 10, 0, 8, (100 + 202 + 515) / 8.0},
+		{"limit stops the walk", asks, sideBuy, 8, 101, 3, (100 + 202) / 3.0},
+		{"limit below the book", asks, sideBuy, 1, 99.5, 0, 0},
+		{"sell slips down", bids, sideSell, 2, 0, 2, 98.5},
+		{"sell limit stops the walk", bids, sideSell, 8, 98, 3, (99 + 196) / 3.0},
+		{"empty book", nil, sideBuy, 1, 0, 0, 0},
+	} {
+		filled, avg := walkBook(c.levels, c.side, c.quantity, c.limit)
+		if !near(filled, c.filled) || !near(avg, c.avg) {
+			t.Errorf("%s: walkBook = %g at %g, want %g at %g", c.name, filled, avg, c.filled, c.avg)
+		}
+	}
+}
+
+func TestPaperFill(t *testing.T) {
+	type fill struct {
+		side     string
+		price    float64
+		quantity float64
+	}
+	for _, c := range []struct {
+		name  string
+		fills []fill
+		want  paperPosition
+	}{
+		{"open long", []fill{{sideBuy, 100, 1}}, paperPosition{Quantity: 1, AvgEntry: 100}},
+		{"add to a long", []fill{{sideBuy, 100, 1}, {sideBuy, 110, 3}}, paperPosition{Quantity: 4, AvgEntry: 107.5}},
+		{"reduce a long", []fill{{sideBuy, 100, 2}, {sideSell, 110, 0.5}}, paperPosition{Quantity: 1.5, AvgEntry: 100, RealizedPnL: 5}},
+		{"close a long", []fill{{sideBuy, 100, 2}, {sideSell, 90, 2}}, paperPosition{RealizedPnL: -20}},
+		{"close a short", []fill{{sideSell, 100, 2}, {sideBuy, 90, 2}}, paperPosition{RealizedPnL: 20}},
+		// the closed quantity realizes its PnL, the rest opens a short at the fill price
+		{"flip a long", []fill{{sideBuy, 100, 1}, {sideSell, 120, 3}}, paperPosition{Quantity: -2, AvgEntry: 120, RealizedPnL: 20}},
+		{"flip a short", []fill{{sideSell, 100, 1}, {sideBuy, 105, 1.5}}, paperPosition{Quantity: 0.5, AvgEntry: 105, RealizedPnL: -5}},
+		// a quantity left over from float rounding doesn't keep the position open
+		{"close in steps", []fill{{sideBuy, 100, 0.3}, {sideSell, 100, 0.1}, {sideSell, 100, 0.2}}, paperPosition{}},
+	} {
+		a := &paperAccount{Positions: make(map[string]*paperPosition)}
+		for _, f := range c.fills {
+			a.fill(paperFill{Symbol: "BTCUSDT", Side: f.side, Price: f.price, Quantity: f.quantity})
+		}
+		got := a.position("BTCUSDT")
+		if !near(got.Quantity, c.want.Quantity) || !near(got.AvgEntry, c.want.AvgEntry) || !near(got.RealizedPnL, c.want.RealizedPnL) {
+			t.Errorf("%s: position = %+v, want %+v", c.name, got, c.want)
+		}
+		if len(a.Fills) != len(c.fills) {
+			t.Errorf("%s: %d fills kept, want %d", c.name, len(a.Fills), len(c.fills))
+		}
+	}
+}
+
+func TestCrossed(t *testing.T) {
+	placed := time.Unix(1000, 0)
+	book := func(bid, ask string, trades ...TradeEvent) *marketSnapshot {
+		return &marketSnapshot{
+			Symbol:  "BTCUSDT",
+			BestBid: OrderbookEntry{Price: dec(t, bid)},
+			BestAsk: OrderbookEntry{Price: dec(t, ask)},
+			Trades:  trades,
+		}
+	}
+	buy := paperOrder{Symbol: "BTCUSDT", Side: sideBuy, Price: 100, Quantity: 1, Placed: placed}
+	sell := paperOrder{Symbol: "BTCUSDT", Side: sideSell, Price: 105, Quantity: 1, Placed: placed}
+	for _, c := range []struct {
+		name  string
+		m     *marketSnapshot
+		order paperOrder
+		want  bool
+	}{
+		{"buy below the ask", book("99", "101"), buy, false},
+		{"ask at the buy", book("99", "100"), buy, true},
+		{"ask below the buy", book("98", "99.5"), buy, true},
+		{"bid at the sell", book("105", "106"), sell, true},
+		{"bid below the sell", book("104", "106"), sell, false},
+		{"empty book", book("0", "0"), buy, false},
+		// a sell printed below the buy after it was placed traded through its queue
+		{"sold through the buy", book("100", "101", trade(t, "99.9", "1", true, 1001)), buy, true},
+		{"sold at the buy", book("100", "101", trade(t, "100", "1", true, 1001)), buy, false},
+		{"bought below the buy", book("100", "101", trade(t, "99.9", "1", false, 1001)), buy, false},
+		{"sold through before placing", book("100", "101", trade(t, "99.9", "1", true, 999)), buy, false},
+		{"bought through the sell", book("104", "106", trade(t, "105.1", "1", false, 1001)), sell, true},
+		// the trades are newest first, the walk stops at the first one older than the order
+		{"bought through before placing", book("104", "106", trade(t, "104", "1", false, 1001), trade(t, "106", "1", false, 999)), sell, false},
+	} {
+		if got := crossed(c.m, c.order); got != c.want {
+			t.Errorf("%s: crossed = %t, want %t", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPaperMatch(t *testing.T) {
+	placed := time.Unix(1000, 0)
+	a := &paperAccount{Positions: make(map[string]*paperPosition)}
+	a.Orders = []paperOrder{
+		{ID: 1, Symbol: "BTCUSDT", Side: sideBuy, Price: 100, Quantity: 1, Placed: placed},
+		{ID: 2, Symbol: "BTCUSDT", Side: sideBuy, Price: 95, Quantity: 2, Placed: placed},
+		{ID: 3, Symbol: "ETHUSDT", Side: sideBuy, Price: 5000, Quantity: 1, Placed: placed},
+		{ID: 4, Symbol: "BTCUSDT", Side: sideSell, Price: 110, Quantity: 0.5, Placed: placed},
+	}
+	m := &marketSnapshot{
+		Symbol:   "BTCUSDT",
+		TickSize: dec(t, "0.1"),
+		BestBid:  OrderbookEntry{Price: dec(t, "98")},
+		BestAsk:  OrderbookEntry{Price: dec(t, "99")},
+	}
+	if !a.match(m) {
+		t.Fatal("match reported no fill")
+	}
+	// only the BTC buy at 100 was crossed, it fills at its own price
+	var ids []int64
+	for _, o := range a.Orders {
+		ids = append(ids, o.ID)
+	}
+	if len(ids) != 3 || ids[0] != 2 || ids[1] != 3 || ids[2] != 4 {
+		t.Errorf("resting orders = %v, want [2 3 4]", ids)
+	}
+	if p := a.position("BTCUSDT"); p.Quantity != 1 || p.AvgEntry != 100 {
+		t.Errorf("position = %+v", p)
+	}
+	if a.match(m) {
+		t.Error("match filled again without a new cross")
+	}
+}
+
+// A market order larger than the shown depth walks the deeper levels the market publishes for the paper fills
+func TestMarketOrderBeyondShownDepth(t *testing.T) {
+	m := newMarket("btcusdt", nil, 10, dec(t, "0.1"), nil)
+	m.rawDepth = paperDepth
+	for i := 0; i < 30; i++ {
+		m.ob.addAsk(NewDecimal(int64(10000+i)*100000000), NewDecimal(100000000))
+		m.ob.addBid(NewDecimal(int64(9999-i)*100000000), NewDecimal(100000000))
+	}
+	m.publish()
+	snap := m.load()
+	if len(snap.Asks) != 10 || len(snap.RawAsks) != 30 {
+		t.Fatalf("snapshot has %d asks shown and %d raw, want 10 and 30", len(snap.Asks), len(snap.RawAsks))
+	}
+
+	a := &paperAccount{NextID: 1, Positions: make(map[string]*paperPosition)}
+	if err := a.marketOrder(snap, sideBuy, 25, time.Unix(1000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	// 25 levels of one each from 10000 to 10024
+	if p := a.position("BTCUSDT"); p.Quantity != 25 || !near(p.AvgEntry, 10012) {
+		t.Errorf("position = %+v, want 25 at 10012", p)
+	}
+	if strings.Contains(a.Message, "cancelled") {
+		t.Errorf("message = %q, nothing should be cancelled", a.Message)
+	}
+
+	if err := a.marketOrder(snap, sideSell, 40, time.Unix(1001, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(a.Message, ", 10 cancelled") {
+		t.Errorf("message = %q, want the 10 beyond the book cancelled", a.Message)
+	}
+}
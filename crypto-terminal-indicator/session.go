@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum size of a single line of a session file
+const maxSessionLine = 16 << 20
+
+// A session file is a JSON line per entry. The first line is a `sessionHeader`,
+// every following line is a `sessionEntry` holding either a raw frame of the stream
+// or the result of fetching a depth snapshot. The frames are kept as strings rather than embedded JSON,
+// so a frame which isn't valid JSON is recorded and replayed as it was received.
+
+// Structure representing the first line of a session file
+type sessionHeader struct {
+	Exchange  string             `json:"exchange"`
+	Symbols   []string           `json:"symbols"`
+	TickSizes map[string]Decimal `json:"tick_sizes"`
+}
+
+// Structure representing a recorded frame or snapshot together with the time it was received
+type sessionEntry struct {
+	Time     time.Time   `json:"time"`
+	Frame    string      `json:"frame,omitempty"`
+	Symbol   string      `json:"symbol,omitempty"`
+	Snapshot *DepthEvent `json:"snapshot,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// Structure writing a session file
+type recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// `newRecorder()` is a constructor function for creating a new instance of `recorder` struct,
+// it writes the header of the session right away
+func newRecorder(w io.Writer, header sessionHeader) (*recorder, error) {
+	r := &recorder{enc: json.NewEncoder(w)}
+	if err := r.enc.Encode(header); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Function to write an entry to the session, errors are logged as recording must not stop the feed
+func (r *recorder) write(e sessionEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(e); err != nil {
+		log.Printf("recording session: %v", err)
+	}
+}
+
+// Structure wrapping an `Exchange` so that every frame it decodes is recorded
+type recordingExchange struct {
+	Exchange
+	rec *recorder
+}
+
+// Structure wrapping an `Exchange` which fetches snapshots, the snapshots are recorded as well
+type recordingFetcher struct {
+	recordingExchange
+	fetcher SnapshotFetcher
+}
+
+// `newRecordingExchange()` is a constructor function wrapping an exchange to record its frames and snapshots.
+// The returned exchange only implements `SnapshotFetcher` when the wrapped one does.
+func newRecordingExchange(ex Exchange, rec *recorder) Exchange {
+	r := recordingExchange{Exchange: ex, rec: rec}
+	if fetcher, ok := ex.(SnapshotFetcher); ok {
+		return &recordingFetcher{recordingExchange: r, fetcher: fetcher}
+	}
+	return &r
+}
+
+func (r *recordingExchange) Decode(msg []byte) ([]Event, error) {
+	// `msg` is reused by the websocket reader, the conversion copies it
+	r.rec.write(sessionEntry{Time: time.Now(), Frame: string(msg)})
+	return r.Exchange.Decode(msg)
+}
+
+func (r *recordingFetcher) FetchSnapshot(symbol string) (*DepthEvent, error) {
+	snap, err := r.fetcher.FetchSnapshot(symbol)
+	e := sessionEntry{Time: time.Now(), Symbol: symbol, Snapshot: snap}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.rec.write(e)
+	return snap, err
+}
+
+// Structure wrapping an `Exchange` during a replay, its snapshots come from the session instead of REST
+type replayExchange struct {
+	Exchange
+	snapshots map[string][]sessionEntry // recorded snapshots per symbol, in the order they were fetched
+}
+
+func (r *replayExchange) FetchSnapshot(symbol string) (*DepthEvent, error) {
+	queue := r.snapshots[symbol]
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("no recorded snapshot left for %s", symbol)
+	}
+	e := queue[0]
+	r.snapshots[symbol] = queue[1:]
+	if e.Error != "" {
+		return nil, errors.New(e.Error)
+	}
+	return e.Snapshot, nil
+}
+
+// Structure reading a session file for a replay
+type session struct {
+	header  sessionHeader
+	scanner *bufio.Scanner
+}
+
+// `openSession()` is a constructor function for creating a new instance of `session` struct,
+// it reads the header of the session right away
+func openSession(r io.Reader) (*session, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxSessionLine)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("empty session")
+	}
+	s := &session{scanner: scanner}
+	if err := json.Unmarshal(scanner.Bytes(), &s.header); err != nil {
+		return nil, fmt.Errorf("session header: %w", err)
+	}
+	return s, nil
+}
+
+// Function to wrap the exchange of the session, so its snapshots are served from the session.
+// Exchanges which send snapshots in the stream are returned as they are.
+func (s *session) exchange(ex Exchange) Exchange {
+	if _, ok := ex.(SnapshotFetcher); !ok {
+		return ex
+	}
+	return &replayExchange{Exchange: ex, snapshots: make(map[string][]sessionEntry)}
+}
+
+// Function to feed the recorded frames through the feed, keeping the recorded pace divided by `speed`.
+// A speed of zero replays the session as fast as possible.
+// The snapshots of a frame are recorded right after it, so they are read ahead of delivering the frame.
+func (s *session) replay(f *feed, speed float64) error {
+	replayer, _ := f.exchange.(*replayExchange)
+	var (
+		pending  *sessionEntry
+		prevTime time.Time
+	)
+	deliver := func(e *sessionEntry) {
+		if speed > 0 && !prevTime.IsZero() {
+			time.Sleep(time.Duration(float64(e.Time.Sub(prevTime)) / speed))
+		}
+		prevTime = e.Time
+		if err := f.handleMessage([]byte(e.Frame)); err != nil {
+			log.Printf("replay: %v", err)
+		}
+	}
+
+	for s.scanner.Scan() {
+		var e sessionEntry
+		if err := json.Unmarshal(s.scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("session entry: %w", err)
+		}
+		if e.Frame == "" {
+			if replayer != nil {
+				replayer.snapshots[e.Symbol] = append(replayer.snapshots[e.Symbol], e)
+			}
+			continue
+		}
+		if pending != nil {
+			deliver(pending)
+		}
+		pending = &e
+	}
+	if pending != nil {
+		deliver(pending)
+	}
+	return s.scanner.Err()
+}
+
+// Function to parse a replay speed such as "4x", "0.5" or "max"
+func parseSpeed(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "max" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(s, "x")
+	speed, err := strconv.ParseFloat(s, 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid speed %q", s)
+	}
+	return speed, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Function to start a fake websocket stream which sends the frames to every connection and closes it afterwards
+func newStreamServer(t *testing.T, frames []string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrading the stream: %v", err)
+			return
+		}
+		defer conn.Close()
+		for _, frame := range frames {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+				t.Errorf("writing a frame: %v", err)
+				return
+			}
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Function to get the frames of a session with a gap, so that the book is seeded from two snapshots
+func sessionFrames() []string {
+	var frames []string
+	depth := func(first, final, prev int64, ask, bid int) {
+		frames = append(frames, fmt.Sprintf(`{"stream":"btcusdt@depth","data":{"U":%d,"u":%d,"pu":%d,"a":[["%d.5","%d"]],"b":[["%d","%d"]]}}`,
+			first, final, prev, ask, ask%3, bid, bid%4))
+	}
+	for i := 0; i < 50; i++ {
+		id := int64(101 + i)
+		depth(id, id, id-1, 27010+i%10, 27000-i%10)
+		frames = append(frames,
+			fmt.Sprintf(`{"stream":"btcusdt@aggTrade","data":{"p":"%d","q":"0.%d","m":%t,"T":%d}}`, 27000+i%7, 1+i%9, i%2 == 0, 1700000000000+int64(i)*1000),
+			fmt.Sprintf(`{"stream":"btcusdt@markPrice","data":{"p":"%d.25","i":"27000","r":"0.0001","T":1700006400000}}`, 27000+i%5),
+		)
+	}
+	// the first `pu` skips ahead, so the book is dropped and seeded from the second snapshot
+	depth(201, 201, 199, 27020, 26990)
+	for i := 1; i < 20; i++ {
+		id := int64(201 + i)
+		depth(id, id, id-1, 27020+i%5, 26990-i%5)
+	}
+	// malformed frames, one of them not even JSON, are recorded as well and dropped again during the replay
+	return append(frames, `{"stream":"btcusdt@depth","data":{"U":"x"}}`, `{"stream":"btcusdt@depth","data":{"U":20`)
+}
+
+func TestSessionReplayMatchesLiveBook(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	rest := newSnapshotServer(t,
+		`{"lastUpdateId":100,"bids":[["27000","1"],["26999","2"]],"asks":[["27001","1"],["27002","3"]]}`,
+		`{"lastUpdateId":200,"bids":[["26990","4"]],"asks":[["27020.5","2"]]}`,
+	)
+	frames := sessionFrames()
+	stream := newStreamServer(t, frames)
+	ws := "ws" + strings.TrimPrefix(stream.URL, "http")
+
+	// the live feed reads the whole stream once and records every frame and snapshot
+	var session bytes.Buffer
+	rec, err := newRecorder(&session, sessionHeader{Exchange: "binance", Symbols: []string{"btcusdt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := newRecordingExchange(newBinance(ws, rest.URL, rest.Client()), rec)
+	live := newMarket("btcusdt", ex, 10, defaultTickSize, nil)
+	f := newFeed(ex, []string{"btcusdt"}, map[string]*market{"btcusdt": live}, time.Second)
+	conn, err := f.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.read(conn)
+	conn.Close()
+	if n := rest.fetched.Load(); n != 2 {
+		t.Fatalf("fetched %d snapshots, want 2", n)
+	}
+	// every frame is in the session as it was received, in the order it was received
+	var recorded []string
+	for _, line := range strings.Split(strings.TrimSpace(session.String()), "\n")[1:] {
+		var e sessionEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("session entry %s: %v", line, err)
+		}
+		if e.Frame != "" {
+			recorded = append(recorded, e.Frame)
+		}
+	}
+	if !reflect.DeepEqual(recorded, frames) {
+		t.Fatalf("recorded %d frames, want the %d sent, the last ones are %q", len(recorded), len(frames), recorded[len(recorded)-2:])
+	}
+
+	// the replay has no network, its snapshots come from the session
+	s, err := openSession(bytes.NewReader(session.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	speed, err := parseSpeed("max")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rex := s.exchange(newBinance("", "", nil))
+	replayed := newMarket("btcusdt", rex, 10, defaultTickSize, nil)
+	rf := newFeed(rex, s.header.Symbols, map[string]*market{"btcusdt": replayed}, time.Second)
+	if err := s.replay(rf, speed); err != nil {
+		t.Fatal(err)
+	}
+
+	want, got := live.load(), replayed.load()
+	if len(want.RawAsks) == 0 || len(want.RawBids) == 0 || len(want.Trades) == 0 {
+		t.Fatalf("live market is empty: %+v", want)
+	}
+	if !reflect.DeepEqual(got.RawAsks, want.RawAsks) || !reflect.DeepEqual(got.RawBids, want.RawBids) {
+		t.Errorf("replayed book\nasks %v\nbids %v\nwant\nasks %v\nbids %v", got.RawAsks, got.RawBids, want.RawAsks, want.RawBids)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed snapshot\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	ui "github.com/gizak/termui/v3"
+)
+
+// Structure representing a color theme. Every field is a termui style such as "fg:green" or
+// "fg:white,mod:bold", which is used in the `[text](style)` markup of the widgets.
+type Theme struct {
+	Up        string `yaml:"up"`        // bids, buys, gains and a rising price
+	Down      string `yaml:"down"`      // asks, sells, losses, a falling price and errors
+	Accent    string `yaml:"accent"`    // symbols, volumes and secondary figures
+	Warn      string `yaml:"warn"`      // figures approaching a threshold
+	Text      string `yaml:"text"`      // everything else
+	Highlight string `yaml:"highlight"` // whale levels and cross venue arbitrage
+	Alert     string `yaml:"alert"`     // a new alert
+}
+
+// All the built in themes keyed by their `--theme` name.
+// The colorblind theme avoids telling sides apart by red and green only, blue and yellow stay
+// distinguishable with the common color vision deficiencies. The monochrome theme uses modifiers only.
+var themes = map[string]Theme{
+	"default": {
+		Up:        "fg:green",
+		Down:      "fg:red",
+		Accent:    "fg:cyan",
+		Warn:      "fg:yellow",
+		Text:      "fg:white",
+		Highlight: "fg:black,bg:magenta",
+		Alert:     "fg:black,bg:yellow",
+	},
+	"colorblind": {
+		Up:        "fg:blue",
+		Down:      "fg:yellow",
+		Accent:    "fg:cyan",
+		Warn:      "fg:magenta",
+		Text:      "fg:white",
+		Highlight: "fg:black,bg:cyan",
+		Alert:     "fg:black,bg:white",
+	},
+	"monochrome": {
+		Up:        "fg:white,mod:bold",
+		Down:      "fg:white,mod:underline",
+		Accent:    "fg:white",
+		Warn:      "fg:white,mod:bold",
+		Text:      "fg:white",
+		Highlight: "fg:white,mod:reverse",
+		Alert:     "fg:white,mod:reverse",
+	},
+}
+
+// Theme the widgets are drawn with, it is set once at startup
+var theme = themes["default"]
+
+// Function to look up a theme by name and apply the overrides of the non-empty fields of `colors`
+func lookupTheme(name string, colors Theme) (Theme, error) {
+	t, ok := themes[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(themes))
+		for n := range themes {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return t, fmt.Errorf("unknown theme %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	for _, o := range []struct {
+		field    *string
+		override string
+	}{
+		{&t.Up, colors.Up},
+		{&t.Down, colors.Down},
+		{&t.Accent, colors.Accent},
+		{&t.Warn, colors.Warn},
+		{&t.Text, colors.Text},
+		{&t.Highlight, colors.Highlight},
+		{&t.Alert, colors.Alert},
+	} {
+		if o.override != "" {
+			*o.field = o.override
+		}
+	}
+	return t, nil
+}
+
+// Function to get the foreground color of a style, for the widgets which take a color rather than markup.
+// Styles without a known foreground color give the default color of the terminal.
+func styleColor(style string) ui.Color {
+	for _, part := range strings.Split(style, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if key == "fg" {
+			if c, ok := ui.StyleParserColorMap[value]; ok {
+				return c
+			}
+		}
+	}
+	return ui.ColorClear
+}
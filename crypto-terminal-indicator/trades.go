@@ -0,0 +1,69 @@
+package main
+
+import "time"
+
+// Number of recent trades kept per market for the time & sales panel
+const maxTrades = 256
+
+// Structure representing a bounded ring buffer of the most recent trades
+type tradeRing struct {
+	buf  []TradeEvent
+	next int // index the next trade is written to
+}
+
+// `newTradeRing()` is a constructor function for creating a new instance of `tradeRing` struct
+func newTradeRing(size int) *tradeRing {
+	return &tradeRing{buf: make([]TradeEvent, 0, size)}
+}
+
+// Function to add a trade, overwriting the oldest one once the buffer is full
+func (r *tradeRing) push(t TradeEvent) {
+	if len(r.buf) < cap(r.buf) {
+		r.buf = append(r.buf, t)
+	} else {
+		r.buf[r.next] = t
+	}
+	r.next = (r.next + 1) % cap(r.buf)
+}
+
+// Function to copy the trades out of the buffer, newest first
+func (r *tradeRing) recent() []TradeEvent {
+	trades := make([]TradeEvent, len(r.buf))
+	for i := range trades {
+		trades[i] = r.buf[(r.next-1-i+2*cap(r.buf))%cap(r.buf)]
+	}
+	return trades
+}
+
+// Structure holding every trade within a time span of the latest trade, oldest first.
+// It backs the order flow statistics, which need all the trades of their window and not just the latest ones.
+type tradeWindow struct {
+	span   time.Duration
+	trades []TradeEvent
+	start  int // index of the oldest trade still within the span
+}
+
+// `newTradeWindow()` is a constructor function for creating a new instance of `tradeWindow` struct
+func newTradeWindow(span time.Duration) *tradeWindow {
+	return &tradeWindow{span: span}
+}
+
+// Function to add a trade and drop the trades which are older than the span before it
+func (w *tradeWindow) push(t TradeEvent) {
+	w.trades = append(w.trades, t)
+	cutoff := t.Time.Add(-w.span)
+	for w.start < len(w.trades) && w.trades[w.start].Time.Before(cutoff) {
+		w.start++
+	}
+	// the dropped trades are reclaimed once they make up half of the buffer
+	if w.start > len(w.trades)/2 {
+		w.trades = append(w.trades[:0], w.trades[w.start:]...)
+		w.start = 0
+	}
+}
+
+// Function to get the trades within the span, oldest first.
+// The slice is shared with the window, so it must not be kept across pushes.
+func (w *tradeWindow) all() []TradeEvent {
+	return w.trades[w.start:]
+}
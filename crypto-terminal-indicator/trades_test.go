@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTradeWindow(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		span   time.Duration
+		pushes []int64 // seconds of the pushed trades
+		want   []int64 // seconds of the trades left within the span
+	}{
+		{"within the span", 10 * time.Second, []int64{0, 5, 10}, []int64{0, 5, 10}},
+		{"old trades dropped", 10 * time.Second, []int64{0, 5, 12, 16}, []int64{12, 16}},
+		{"a gap drops every older trade", 10 * time.Second, []int64{0, 1, 2, 100}, []int64{100}},
+		// enough trades are dropped for the buffer to be compacted several times
+		{"compaction", 5 * time.Second, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, []int64{14, 15, 16, 17, 18, 19}},
+	} {
+		w := newTradeWindow(c.span)
+		for _, at := range c.pushes {
+			w.push(trade(t, "100", "1", false, at))
+		}
+		got := []int64{}
+		for _, tr := range w.all() {
+			got = append(got, tr.Time.Unix())
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: trades at %v, want %v", c.name, got, c.want)
+		}
+	}
+}
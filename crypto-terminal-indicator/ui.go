@@ -1,4 +1,787 @@
-package main
-
-type Panel struct {
-}
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+)
+
+type Panel struct {
+}
+
+// Multiples of the tick size the orderbook levels can be grouped by, switched with the `g` key
+var bookGroupings = []int64{1, 10, 100}
+
+// Partial blocks used to draw the cumulative volume bars with a resolution of an eighth of a cell
+var barBlocks = []rune(" ▏▎▍▌▋▊▉█")
+
+// Function to format the orderbook as rows of price, volume and cumulative volume bar.
+// The asks are listed worst first so that the best ask sits right above the best bid, and the bars
+// of both sides are scaled to the largest cumulative volume shown. Missing levels are shown as n/a.
+// Levels with more than `whale` times the median volume of their side are highlighted.
+func getBookRows(m *marketSnapshot, depth, barWidth int, whale float64) [][]string {
+	places := m.TickSize.Places()
+	if m.Bucket.Sign() > 0 {
+		places = m.Bucket.Places()
+	}
+	askCum := cumulativeVolumes(m.Asks)
+	bidCum := cumulativeVolumes(m.Bids)
+	maxCum := 0.0
+	if n := len(askCum); n > 0 {
+		maxCum = askCum[n-1]
+	}
+	if n := len(bidCum); n > 0 && bidCum[n-1] > maxCum {
+		maxCum = bidCum[n-1]
+	}
+
+	askWhales := whaleLevels(m.Asks, whale)
+	bidWhales := whaleLevels(m.Bids, whale)
+
+	out := make([][]string, 2*depth)
+	for i := 0; i < depth; i++ {
+		// the best ask goes to the last ask row
+		row := depth - 1 - i
+		if i < len(m.Asks) {
+			out[row] = []string{
+				fmt.Sprintf("[%s](%s)", m.Asks[i].Price.StringFixed(places), theme.Down),
+				formatBookVolume(m.Asks[i].Volume, askWhales[i]),
+				fmt.Sprintf("[%s](%s)", volumeBar(askCum[i], maxCum, barWidth), theme.Down),
+			}
+		} else {
+			out[row] = []string{"n/a", "n/a", ""}
+		}
+		if i < len(m.Bids) {
+			out[depth+i] = []string{
+				fmt.Sprintf("[%s](%s)", m.Bids[i].Price.StringFixed(places), theme.Up),
+				formatBookVolume(m.Bids[i].Volume, bidWhales[i]),
+				fmt.Sprintf("[%s](%s)", volumeBar(bidCum[i], maxCum, barWidth), theme.Up),
+			}
+		} else {
+			out[depth+i] = []string{"n/a", "n/a", ""}
+		}
+	}
+	return out
+}
+
+// Function to format the volume of a level, whale levels stand out in the highlight style
+func formatBookVolume(volume Decimal, whale bool) string {
+	if whale {
+		return fmt.Sprintf("[%s](%s)", volume, theme.Highlight)
+	}
+	return fmt.Sprintf("[%s](%s)", volume, theme.Accent)
+}
+
+// Function to sum up the volumes of the levels from the best one outwards
+func cumulativeVolumes(levels []OrderbookEntry) []float64 {
+	cum := make([]float64, len(levels))
+	total := 0.0
+	for i, l := range levels {
+		total += l.Volume.Float64()
+		cum[i] = total
+	}
+	return cum
+}
+
+// Function to draw a bar of `width` cells filled in proportion of `value` to `max`
+func volumeBar(value, max float64, width int) string {
+	if max <= 0 {
+		return ""
+	}
+	eighths := int(value / max * float64(width*8))
+	bar := strings.Repeat(string(barBlocks[8]), eighths/8)
+	if eighths%8 > 0 {
+		bar += string(barBlocks[eighths%8])
+	}
+	return bar
+}
+
+// Function to format the spread, the mid price and the imbalance of the book.
+// The imbalance is the share of the bid volume in the volume of all the levels shown,
+// above 0.5 there is more volume bid than offered.
+func getBookStats(m *marketSnapshot) string {
+	if m.BestAsk.Price.Sign() <= 0 || m.BestBid.Price.Sign() <= 0 {
+		return "n/a"
+	}
+	places := m.TickSize.Places()
+	var (
+		spread = m.BestAsk.Price.Sub(m.BestBid.Price)
+		mid    = (m.BestAsk.Price.Float64() + m.BestBid.Price.Float64()) / 2
+		askVol = sumVolume(m.Asks)
+		bidVol = sumVolume(m.Bids)
+	)
+	imbalance := 0.5
+	if askVol+bidVol > 0 {
+		imbalance = bidVol / (askVol + bidVol)
+	}
+	style := theme.Up
+	if imbalance < 0.5 {
+		style = theme.Down
+	}
+	return fmt.Sprintf("Spread [%s](%s) Mid [%.*f](%s) Imb [%.2f](%s)",
+		spread.StringFixed(places), theme.Warn, places+1, mid, theme.Accent, imbalance, style)
+}
+
+// Function to sum up the volume of the levels
+func sumVolume(levels []OrderbookEntry) float64 {
+	total := 0.0
+	for _, l := range levels {
+		total += l.Volume.Float64()
+	}
+	return total
+}
+
+// Function to format the funding rate, its annualized value and the countdown to the next funding.
+// The rate takes the warn style once its annualized value exceeds 30% either way and the down style above 100%,
+// the countdown takes the warn style in the last half hour and the down style in the last five minutes.
+func getFundingText(m *marketSnapshot, now time.Time) string {
+	rate, err := strconv.ParseFloat(m.FundingRate, 64)
+	if err != nil {
+		return m.FundingRate
+	}
+	text := fmt.Sprintf("%.4f%%", rate*100)
+	if m.FundingInterval > 0 {
+		annualized := rate * float64(365*24*time.Hour) / float64(m.FundingInterval)
+		style := theme.Up
+		switch {
+		case math.Abs(annualized) > 1:
+			style = theme.Down
+		case math.Abs(annualized) > 0.3:
+			style = theme.Warn
+		}
+		text = fmt.Sprintf("[%s %.2f%%/y](%s)", text, annualized*100, style)
+	}
+	if m.NextFundingTime.IsZero() {
+		return text
+	}
+	left := m.NextFundingTime.Sub(now)
+	if left < 0 {
+		left = 0
+	}
+	style := theme.Text
+	switch {
+	case left < 5*time.Minute:
+		style = theme.Down
+	case left < 30*time.Minute:
+		style = theme.Warn
+	}
+	left = left.Truncate(time.Second)
+	return fmt.Sprintf("%s [in %02d:%02d:%02d](%s)", text,
+		int(left.Hours()), int(left.Minutes())%60, int(left.Seconds())%60, style)
+}
+
+// Function to format the index price and the premium of the mark price over it.
+// The premium takes the warn style from 0.05% and the down style from 0.2% either way.
+func getPremiumText(m *marketSnapshot) string {
+	if m.IndexPrice <= 0 || m.CurrMarkPrice <= 0 {
+		return "n/a"
+	}
+	premium := (m.CurrMarkPrice - m.IndexPrice) / m.IndexPrice
+	style := theme.Up
+	switch {
+	case math.Abs(premium) >= 0.002:
+		style = theme.Down
+	case math.Abs(premium) >= 0.0005:
+		style = theme.Warn
+	}
+	return fmt.Sprintf("[%.*f](%s) [%+.3f%%](%s)", m.TickSize.Places(), m.IndexPrice, theme.Accent, premium*100, style)
+}
+
+// Function to format the order flow as rows of window, VWAP, volume delta and buy share,
+// followed by the cumulative delta since the start. The deltas take the up style when buyers were more aggressive.
+func getFlowRows(m *marketSnapshot) [][]string {
+	places := m.TickSize.Places()
+	out := make([][]string, 0, len(m.Flow)+2)
+	out = append(out, []string{"Window", "VWAP", "Delta", "Buy"})
+	for _, s := range m.Flow {
+		vwap, buys := "n/a", "n/a"
+		if total := s.BuyVolume + s.SellVolume; total > 0 {
+			vwap = fmt.Sprintf("%.*f", places, s.VWAP)
+			buys = fmt.Sprintf("%.0f%%", s.BuyVolume/total*100)
+		}
+		out = append(out, []string{formatWindow(s.Window), vwap, formatDelta(s.Delta()), buys})
+	}
+	out = append(out, []string{"CVD", "", formatDelta(m.CumDelta), ""})
+	return out
+}
+
+// Function to format a window without its zero units, e.g. 5m rather than 5m0s
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// Function to format a volume delta with its sign, in the up style when positive and the down style when negative
+func formatDelta(delta float64) string {
+	style := theme.Up
+	if delta < 0 {
+		style = theme.Down
+	}
+	return fmt.Sprintf("[%+.3f](%s)", delta, style)
+}
+
+// Function to format the paper position of the market with its PnL marked to the mark price,
+// the order size, the resting orders and the outcome of the last action
+func getPaperText(a *paperAccount, m *marketSnapshot, size float64) string {
+	places := m.TickSize.Places()
+	p := a.position(m.Symbol)
+	text := "Flat"
+	if p.Quantity != 0 {
+		text = fmt.Sprintf("Pos [%+g](%s) @ %.*f uPnL %s", p.Quantity, sideStyle(p.Quantity),
+			places, p.AvgEntry, formatPnL(p.unrealizedPnL(m.CurrMarkPrice)))
+	}
+	text += fmt.Sprintf(" rPnL %s Size %g", formatPnL(p.RealizedPnL), size)
+	for _, o := range a.openOrders(m.Symbol) {
+		style := theme.Up
+		if o.Side == sideSell {
+			style = theme.Down
+		}
+		text += fmt.Sprintf(" [%s %g@%.*f](%s)", o.Side, o.Quantity, places, o.Price, style)
+	}
+	if a.Message != "" {
+		text += " | " + a.Message
+	}
+	return text
+}
+
+// Function to get the style of a signed quantity, the up style when long and the down style when short
+func sideStyle(quantity float64) string {
+	if quantity < 0 {
+		return theme.Down
+	}
+	return theme.Up
+}
+
+// Function to format a PnL with its sign, in the up style for a profit and the down style for a loss
+func formatPnL(pnl float64) string {
+	return fmt.Sprintf("[%+.2f](%s)", pnl, sideStyle(pnl))
+}
+
+// Function to format the latest alerts as the text of the alerts panel, newest first.
+// While `flash` is set the latest alert is highlighted, the render loop toggles it to make the alert blink.
+func getAlertText(alerts []Alert, flash bool) string {
+	if len(alerts) == 0 {
+		return "no alerts"
+	}
+	style := theme.Warn
+	if flash {
+		style = theme.Alert
+	}
+	text := fmt.Sprintf("[%s %s](%s)", alerts[0].Time.Local().Format("15:04:05"), alerts[0].Text, style)
+	for _, a := range alerts[1:] {
+		text += fmt.Sprintf("  %s %s", a.Time.Local().Format("15:04:05"), a.Text)
+	}
+	return text
+}
+
+// Function to create the time & sales panel listing the latest trades
+func newTradesPanel() *widgets.Table {
+	tape := widgets.NewTable()
+	tape.Title = "Time & sales"
+	tape.TextStyle = ui.NewStyle(styleColor(theme.Text))
+	tape.PaddingBottom = 0
+	tape.PaddingTop = 0
+	tape.RowSeparator = false
+	tape.TextAlignment = ui.AlignCenter
+	return tape
+}
+
+// Function to format the latest trades as rows of the time & sales panel, at most `rows` of them.
+// The price and size are styled by the aggressor side, the up style when the buyer took the offer
+// and the down style when the seller hit the bid.
+func getTradeRows(m *marketSnapshot, rows int) [][]string {
+	if rows > len(m.Trades) {
+		rows = len(m.Trades)
+	}
+	places := m.TickSize.Places()
+	out := make([][]string, 0, rows+1)
+	out = append(out, []string{"Time", "Price", "Size"})
+	for _, t := range m.Trades[:rows] {
+		style := theme.Up
+		if t.BuyerMaker {
+			style = theme.Down
+		}
+		out = append(out, []string{
+			t.Time.Local().Format("15:04:05"),
+			fmt.Sprintf("[%s](%s)", t.Price.StringFixed(places), style),
+			fmt.Sprintf("[%s](%s)", t.Volume, style),
+		})
+	}
+	return out
+}
+
+// Function to format the latest liquidations as rows of time, liquidated side, price and notional, at most `rows` of them.
+// A liquidated long takes the down style and a liquidated short the up style, as the positions are closed by
+// a sell and a buy. Liquidations worth more than `large` in the quote currency take the highlight style.
+func getLiquidationRows(m *marketSnapshot, rows int, large float64) [][]string {
+	if rows > len(m.Liquidations) {
+		rows = len(m.Liquidations)
+	}
+	places := m.TickSize.Places()
+	out := make([][]string, 0, rows+1)
+	out = append(out, []string{"Time", "Side", "Price", "Value"})
+	for _, l := range m.Liquidations[:rows] {
+		side, style := "short", theme.Up
+		if l.Side == sideSell {
+			side, style = "long", theme.Down
+		}
+		notional := l.Price.Float64() * l.Volume.Float64()
+		value := fmt.Sprintf("[%s](%s)", formatNotional(notional), style)
+		if large > 0 && notional >= large {
+			value = fmt.Sprintf("[%s](%s)", formatNotional(notional), theme.Highlight)
+		}
+		out = append(out, []string{
+			l.Time.Local().Format("15:04:05"),
+			fmt.Sprintf("[%s](%s)", side, style),
+			fmt.Sprintf("[%s](%s)", l.Price.StringFixed(places), style),
+			value,
+		})
+	}
+	return out
+}
+
+// Function to format a notional value with a metric suffix, e.g. 12.3k or 1.25M
+func formatNotional(v float64) string {
+	switch {
+	case v >= 1e9:
+		return fmt.Sprintf("%.2fB", v/1e9)
+	case v >= 1e6:
+		return fmt.Sprintf("%.2fM", v/1e6)
+	case v >= 1e3:
+		return fmt.Sprintf("%.1fk", v/1e3)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+// Structure representing the open interest panel, which plots the change of the open interest
+// and of the mark price since the first sample
+type openInterestPanel struct {
+	plot *widgets.Plot
+}
+
+// Function to create the open interest panel, the open interest and the mark price are drawn as two lines
+func newOpenInterestPanel() *openInterestPanel {
+	plot := widgets.NewPlot()
+	plot.ShowAxes = false
+	plot.LineColors = []ui.Color{styleColor(theme.Accent), styleColor(theme.Text)}
+	return &openInterestPanel{plot: plot}
+}
+
+// Function to fill the panel with the open interest samples of the market.
+// Both lines are drawn relative to the lowest change of either of them, as termui plots start at zero.
+func (p *openInterestPanel) update(m *marketSnapshot) {
+	samples := m.OpenInterest
+	// one sample per column of the plot
+	if width := p.plot.Inner.Dx(); len(samples) > width && width > 0 {
+		samples = samples[len(samples)-width:]
+	}
+	if len(samples) == 0 {
+		p.plot.Title = "Open interest n/a"
+	} else {
+		last := samples[len(samples)-1]
+		p.plot.Title = fmt.Sprintf("OI %s", formatNotional(last.OpenInterest))
+	}
+
+	// A line needs at least two points and termui divides by the highest value
+	if len(samples) < 2 {
+		p.plot.Data = [][]float64{{0, 0}}
+		p.plot.MaxVal = 1
+		return
+	}
+	oi, mark := openInterestChanges(samples)
+	p.plot.Title += fmt.Sprintf(" %+.2f%% / mark %+.2f%%", oi[len(oi)-1], mark[len(mark)-1])
+
+	low, high := oi[0], oi[0]
+	for _, series := range [][]float64{oi, mark} {
+		for _, v := range series {
+			if v < low {
+				low = v
+			}
+			if v > high {
+				high = v
+			}
+		}
+	}
+	for i := range oi {
+		oi[i] -= low
+		mark[i] -= low
+	}
+	p.plot.Data = [][]float64{oi, mark}
+	p.plot.MaxVal = high - low
+	if p.plot.MaxVal <= 0 {
+		p.plot.MaxVal = 1
+	}
+}
+
+// Structure representing the chart panel, a line chart of the candles above their volume bars
+type chartPanel struct {
+	plot   *widgets.Plot
+	volume *widgets.Sparkline
+	bars   *widgets.SparklineGroup
+}
+
+// Function to create the chart panel, the high, low and close of the candles are drawn as three lines
+func newChartPanel() *chartPanel {
+	plot := widgets.NewPlot()
+	plot.ShowAxes = false
+	plot.LineColors = []ui.Color{styleColor(theme.Up), styleColor(theme.Down), styleColor(theme.Text)}
+
+	volume := widgets.NewSparkline()
+	volume.LineColor = styleColor(theme.Accent)
+	bars := widgets.NewSparklineGroup(volume)
+	bars.Title = "Volume"
+
+	return &chartPanel{plot: plot, volume: volume, bars: bars}
+}
+
+// Function to place the chart panel, the lowest `volumeHeight` rows hold the volume bars
+func (c *chartPanel) SetRect(x1, y1, x2, y2, volumeHeight int) {
+	c.plot.SetRect(x1, y1, x2, y2-volumeHeight)
+	c.bars.SetRect(x1, y2-volumeHeight, x2, y2)
+}
+
+// Function to fill the chart with the candles of the market for the given interval.
+// termui plots start at zero, so the prices are drawn relative to the lowest low of the visible candles.
+func (c *chartPanel) update(m *marketSnapshot, interval time.Duration) {
+	candles := aggregateCandles(m.Candles, interval)
+	// one candle per column of the plot
+	if width := c.plot.Inner.Dx(); len(candles) > width {
+		candles = candles[len(candles)-width:]
+	}
+	c.plot.Title = fmt.Sprintf("%s %s", m.Symbol, formatInterval(interval))
+
+	// A line needs at least two points and termui divides by the highest value
+	if len(candles) < 2 {
+		c.plot.Data = [][]float64{{0, 0}}
+		c.plot.MaxVal = 1
+		c.volume.Data = []float64{0}
+		c.volume.MaxVal = 1
+		return
+	}
+
+	low, high := candles[0].Low, candles[0].High
+	for _, k := range candles {
+		if k.Low < low {
+			low = k.Low
+		}
+		if k.High > high {
+			high = k.High
+		}
+	}
+	var (
+		highs   = make([]float64, len(candles))
+		lows    = make([]float64, len(candles))
+		closes  = make([]float64, len(candles))
+		volumes = make([]float64, len(candles))
+		maxVol  = 0.0
+	)
+	for i, k := range candles {
+		highs[i] = k.High - low
+		lows[i] = k.Low - low
+		closes[i] = k.Close - low
+		volumes[i] = k.Volume
+		if k.Volume > maxVol {
+			maxVol = k.Volume
+		}
+	}
+	c.plot.Data = [][]float64{highs, lows, closes}
+	c.plot.MaxVal = high - low
+	if c.plot.MaxVal == 0 {
+		c.plot.MaxVal = 1
+	}
+	c.volume.Data = volumes
+	c.volume.MaxVal = maxVol
+	if maxVol == 0 {
+		c.volume.MaxVal = 1
+	}
+
+	places := m.TickSize.Places()
+	last := candles[len(candles)-1]
+	c.plot.Title = fmt.Sprintf("%s %s O %.*f H %.*f L %.*f C %.*f", m.Symbol, formatInterval(interval),
+		places, last.Open, places, last.High, places, last.Low, places, last.Close)
+}
+
+// Function to format a candle interval, e.g. 5m
+func formatInterval(d time.Duration) string {
+	return fmt.Sprintf("%dm", int(d/time.Minute))
+}
+
+// Structure holding every widget of the terminal UI together with their current layout
+type dashboard struct {
+	pticker *widgets.Paragraph
+	pprice  *widgets.Paragraph
+	pindex  *widgets.Paragraph
+	pfund   *widgets.Paragraph
+	pstatus *widgets.Paragraph
+	pbook   *widgets.Paragraph
+	tob     *widgets.Table
+	tape    *widgets.Table
+	pflow   *widgets.Table
+	chart   *chartPanel
+	palerts *widgets.Paragraph // nil when no alert rules are loaded
+	ppaper  *widgets.Paragraph // nil when paper trading is off
+	pvenues *widgets.Table     // nil when no other venues are compared
+	pliq    *widgets.Table     // nil when the markets aren't perpetuals
+	oi      *openInterestPanel // nil when the markets aren't perpetuals
+
+	rows     int           // number of ask and bid levels the orderbook table has room for
+	tapeRows int           // number of trades the time & sales panel has room for
+	liqRows  int           // number of liquidations the liquidations panel has room for
+	barWidth int           // width of the cumulative volume bars of the orderbook table
+	visible  []ui.Drawable // widgets which fit on the screen
+	width    int           // size of the screen the widgets are laid out on
+	height   int
+
+	flowHeight   int // height of the order flow panel
+	venuesHeight int // height of the venues panel
+
+	sizes  layoutConfig    // sizes the widgets are laid out with
+	hidden map[string]bool // panels hidden with `--hide`, keyed by the names of `hideablePanels`
+}
+
+// `newDashboard()` is a constructor function for creating a new instance of `dashboard` struct,
+// the widgets are placed by `layout`. The order flow panel has a row for each of the `windows`.
+// The liquidations and open interest panels are only created for `perpetual` markets.
+func newDashboard(name string, alerts bool, windows int, paper bool, venues int, perpetual bool, sizes layoutConfig, hidden map[string]bool) *dashboard {
+	d := &dashboard{
+		sizes:   sizes.withDefaults(),
+		hidden:  hidden,
+		pticker: widgets.NewParagraph(),
+		pprice:  widgets.NewParagraph(),
+		pindex:  widgets.NewParagraph(),
+		pfund:   widgets.NewParagraph(),
+		pstatus: widgets.NewParagraph(),
+		pbook:   widgets.NewParagraph(),
+		tob:     widgets.NewTable(),
+		tape:    newTradesPanel(),
+		pflow:   widgets.NewTable(),
+		chart:   newChartPanel(),
+	}
+	d.pticker.Title = name
+	d.pprice.Title = "Market price"
+	d.pindex.Title = "Index / premium"
+	d.pfund.Title = "Funding"
+	d.pstatus.Title = "Feed"
+	d.pbook.Title = "Book"
+
+	d.tob.TextStyle = ui.NewStyle(styleColor(theme.Text))
+	d.tob.PaddingBottom = 0
+	d.tob.PaddingTop = 0
+	d.tob.RowSeparator = false
+	d.tob.TextAlignment = ui.AlignCenter
+
+	d.pflow.Title = "Order flow"
+	d.pflow.TextStyle = ui.NewStyle(styleColor(theme.Text))
+	d.pflow.PaddingBottom = 0
+	d.pflow.PaddingTop = 0
+	d.pflow.RowSeparator = false
+	d.pflow.TextAlignment = ui.AlignCenter
+	d.pflow.ColumnWidths = []int{8, 11, 10, 6}
+	// a header, a row per window and the cumulative delta, inside the borders
+	d.flowHeight = windows + 4
+
+	if alerts {
+		d.palerts = widgets.NewParagraph()
+		d.palerts.Title = "Alerts"
+	}
+	if venues > 0 {
+		d.pvenues = widgets.NewTable()
+		d.pvenues.Title = "Venues"
+		d.pvenues.TextStyle = ui.NewStyle(styleColor(theme.Text))
+		d.pvenues.PaddingBottom = 0
+		d.pvenues.PaddingTop = 0
+		d.pvenues.RowSeparator = false
+		d.pvenues.TextAlignment = ui.AlignCenter
+		// a header, a row per venue including the selected market and a row per pair of venues, inside the borders
+		n := venues + 1
+		d.venuesHeight = 1 + n + n*(n-1) + 2
+	}
+	if perpetual {
+		d.pliq = widgets.NewTable()
+		d.pliq.Title = "Liquidations"
+		d.pliq.TextStyle = ui.NewStyle(styleColor(theme.Text))
+		d.pliq.PaddingBottom = 0
+		d.pliq.PaddingTop = 0
+		d.pliq.RowSeparator = false
+		d.pliq.TextAlignment = ui.AlignCenter
+		d.oi = newOpenInterestPanel()
+	}
+	if paper {
+		d.ppaper = widgets.NewParagraph()
+		d.ppaper.Title = "Paper trading: b/s market, B/S limit, c cancel, +/- size"
+	}
+	return d
+}
+
+// Function to place the widgets on a screen of the given size.
+// The header panels and the orderbook keep their width, the chart takes the remaining width and
+// the orderbook shows as many of the `depth` levels as fit in the height. Panels which don't fit are hidden.
+func (d *dashboard) layout(width, height, depth int) {
+	margin := *d.sizes.Margin
+	pheight := d.sizes.HeaderHeight
+	d.width, d.height = width, height
+	d.visible = d.visible[:0]
+
+	// The header panels are laid out left to right, the book stats take the remaining width
+	x := 0
+	for _, p := range []struct {
+		widget *widgets.Paragraph
+		width  int
+		name   string
+	}{{d.pticker, 14, ""}, {d.pprice, 16, ""}, {d.pindex, 28, "index"}, {d.pfund, 34, "funding"}, {d.pstatus, 24, ""}, {d.pbook, 50, "book"}} {
+		if d.hidden[p.name] {
+			continue
+		}
+		w := p.width
+		if p.widget == d.pbook && width-x < w {
+			w = width - x
+		}
+		if x+w > width || w < 14 {
+			break
+		}
+		p.widget.SetRect(x, 0, x+w, pheight)
+		d.visible = append(d.visible, p.widget)
+		x += w + margin
+	}
+
+	// The paper trading and alerts panels sit at the bottom of the screen, the body fills the rows in between
+	var bottom []*widgets.Paragraph
+	for _, p := range []*widgets.Paragraph{d.ppaper, d.palerts} {
+		if p != nil {
+			bottom = append(bottom, p)
+		}
+	}
+	bodyTop := pheight + margin
+	bodyBottom := height - len(bottom)*pheight
+	d.rows = (bodyBottom - bodyTop - 2) / 2
+	if d.rows > depth {
+		d.rows = depth
+	}
+	if d.rows < 1 {
+		d.rows = 1
+	}
+	bodyBottom = bodyTop + 2*d.rows + 2
+
+	tobWidth := d.sizes.BookWidth
+	if width < tobWidth {
+		tobWidth = width
+	}
+	// the borders and the column separators take four columns, the bars are padded by one cell on each side
+	barColumn := tobWidth - 4 - 2*13
+	if barColumn < 2 {
+		barColumn = 2
+	}
+	d.barWidth = barColumn - 2
+	d.tob.SetRect(0, bodyTop, tobWidth, bodyBottom)
+	d.tob.ColumnWidths = []int{13, 13, barColumn}
+	d.visible = append(d.visible, d.tob)
+
+	// The time & sales panel shares its column with the order flow panel below it, when there is room for both
+	// and takes all of it when the other one is hidden
+	x = tobWidth + margin
+	tapeWidth := d.sizes.TapeWidth
+	showTape, showFlow := !d.hidden["trades"], !d.hidden["flow"]
+	if (showTape || showFlow) && x+tapeWidth <= width {
+		tapeBottom := bodyBottom
+		switch {
+		case !showTape:
+			tapeBottom = bodyTop
+		case showFlow && bodyBottom-bodyTop-d.flowHeight >= 5:
+			tapeBottom = bodyBottom - d.flowHeight
+		}
+		if showFlow && tapeBottom < bodyBottom {
+			d.pflow.SetRect(x, tapeBottom, x+tapeWidth, bodyBottom)
+			d.visible = append(d.visible, d.pflow)
+		}
+		if showTape {
+			d.tape.SetRect(x, bodyTop, x+tapeWidth, tapeBottom)
+			// the header and the borders take three rows of the table
+			d.tapeRows = tapeBottom - bodyTop - 3
+			d.visible = append(d.visible, d.tape)
+		}
+		x += tapeWidth + margin
+	}
+	// The liquidations panel and the open interest panel below it take a column at the right edge,
+	// as long as the chart keeps room for a few candles
+	right := width
+	showLiq := d.pliq != nil && !d.hidden["liquidations"]
+	showOI := d.oi != nil && !d.hidden["oi"]
+	liqWidth := d.sizes.LiquidationWidth
+	chartRoom := 20 + margin
+	if d.hidden["chart"] && (d.pvenues == nil || d.hidden["venues"]) {
+		chartRoom = 0
+	}
+	if (showLiq || showOI) && width-x >= liqWidth+chartRoom {
+		right = width - liqWidth
+		split := bodyTop
+		switch {
+		case !showOI:
+			split = bodyBottom
+		case showLiq && bodyBottom-bodyTop >= 10:
+			split = bodyTop + (bodyBottom-bodyTop)/2
+		case showLiq:
+			// there is only room for one of them, the liquidations win
+			split = bodyBottom
+			showOI = false
+		}
+		if showLiq {
+			d.pliq.SetRect(right, bodyTop, width, split)
+			// the borders and the column separators take five columns
+			price := liqWidth - 5 - 8 - 5 - 8
+			if price < 1 {
+				price = 1
+			}
+			d.pliq.ColumnWidths = []int{8, 5, price, 8}
+			// the header and the borders take three rows of the table
+			d.liqRows = split - bodyTop - 3
+			d.visible = append(d.visible, d.pliq)
+		}
+		if showOI {
+			d.oi.plot.SetRect(right, split, width, bodyBottom)
+			d.visible = append(d.visible, d.oi.plot)
+		}
+		right -= margin
+	}
+
+	// the chart needs room for a few candles and for its volume bars below the plot,
+	// the venues panel goes below the chart when there is room for both
+	// and takes all of it when the chart is hidden
+	showVenues := d.pvenues != nil && !d.hidden["venues"]
+	if d.hidden["chart"] && showVenues && right-x >= 20 {
+		d.pvenues.SetRect(x, bodyTop, right, bodyBottom)
+		column := (right - x - 2 - 3) / 4
+		d.pvenues.ColumnWidths = []int{column, column, column, column}
+		d.visible = append(d.visible, d.pvenues)
+	}
+	if !d.hidden["chart"] && right-x >= 20 && bodyBottom-bodyTop >= 8 {
+		chartBottom := bodyBottom
+		if showVenues && bodyBottom-bodyTop-d.venuesHeight >= 8 {
+			chartBottom = bodyBottom - d.venuesHeight
+			d.pvenues.SetRect(x, chartBottom, right, bodyBottom)
+			column := (right - x - 2 - 3) / 4
+			d.pvenues.ColumnWidths = []int{column, column, column, column}
+			d.visible = append(d.visible, d.pvenues)
+		}
+		volumeHeight := (chartBottom - bodyTop) / 3
+		if volumeHeight > d.sizes.VolumeHeight {
+			volumeHeight = d.sizes.VolumeHeight
+		}
+		d.chart.SetRect(x, bodyTop, right, chartBottom, volumeHeight)
+		d.visible = append(d.visible, d.chart.plot, d.chart.bars)
+	}
+
+	for i, p := range bottom {
+		p.SetRect(0, bodyBottom+i*pheight, width, bodyBottom+(i+1)*pheight)
+		d.visible = append(d.visible, p)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Structure representing another venue a market is compared with.
+// Every venue has its own adapter, feed and orderbook, only the top of the books are merged for the view.
+// Symbols differ between exchanges, so a venue is only shown while the symbol it is compared with is selected.
+type venue struct {
+	name    string
+	against string // symbol of the subscribed markets the venue is compared with
+	market  *market
+	feed    *feed
+}
+
+// Structure representing the top of the book of a venue
+type venueTop struct {
+	Venue  string
+	Bid    OrderbookEntry // zero when the book is empty
+	Ask    OrderbookEntry // zero when the book is empty
+	Places int            // decimal places of the tick size of the venue
+}
+
+// Structure representing the spread between buying on one venue and selling on another.
+// `Bps` is the bid of `Sell` minus the ask of `Buy` in basis points of that ask,
+// it is positive when the bid of one venue exceeds the ask of the other.
+type crossSpread struct {
+	Buy  string
+	Sell string
+	Bps  float64
+}
+
+// Function to create the venues of a comma separated list of `exchange[:symbol][@against]`, e.g. "binance-spot:ethusdt@ethusdt".
+// The symbol defaults to the exchange's BTC market, `against` is one of the subscribed `symbols` and defaults to the first of them.
+// The feeds have to be started with `run`.
+func parseVenues(s string, symbols []string, client *http.Client, depth int, windows []time.Duration, staleAfter time.Duration) ([]*venue, error) {
+	var venues []*venue
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		spec, against, _ := strings.Cut(spec, "@")
+		against = strings.ToLower(against)
+		if against == "" {
+			against = symbols[0]
+		} else if !containsSymbol(symbols, against) {
+			return nil, fmt.Errorf("compare %s: %s isn't one of the subscribed symbols", spec, against)
+		}
+		name, symbol, _ := strings.Cut(spec, ":")
+		info, err := lookupExchange(name)
+		if err != nil {
+			return nil, err
+		}
+		if symbol == "" {
+			symbol = info.symbols
+		}
+		symbol = strings.ToLower(symbol)
+
+		ex := info.new("", "", client)
+		tick := fetchTickSizes(ex, []string{symbol})[symbol]
+		if tick.Sign() <= 0 {
+			tick = defaultTickSize
+		}
+		m := newMarket(symbol, ex, depth, tick, windows)
+		venues = append(venues, &venue{
+			name:    ex.Name(),
+			against: against,
+			market:  m,
+			feed:    newFeed(ex, []string{symbol}, map[string]*market{symbol: m}, staleAfter),
+		})
+	}
+	return venues, nil
+}
+
+// Function to check if `symbol` is one of `symbols`
+func containsSymbol(symbols []string, symbol string) bool {
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to get the largest number of venues compared with the same symbol, which the venues panel is sized for
+func mostCompared(venues []*venue) int {
+	counts := make(map[string]int)
+	most := 0
+	for _, v := range venues {
+		counts[v.against]++
+		if counts[v.against] > most {
+			most = counts[v.against]
+		}
+	}
+	return most
+}
+
+// Function to get the top of the book of the selected market followed by those of the venues compared with it
+func compareVenues(name string, m *marketSnapshot, venues []*venue) []venueTop {
+	tops := []venueTop{topOfBook(name, m)}
+	for _, v := range venues {
+		if v.against == strings.ToLower(m.Symbol) {
+			tops = append(tops, topOfBook(v.name, v.market.load()))
+		}
+	}
+	return tops
+}
+
+// Function to get the top of the book of a market snapshot
+func topOfBook(name string, m *marketSnapshot) venueTop {
+	return venueTop{Venue: name, Bid: m.BestBid, Ask: m.BestAsk, Places: m.TickSize.Places()}
+}
+
+// Function to compute the spread of buying on each venue and selling on each other one.
+// Venues with an empty side are left out, the spreads are in the order of the venues.
+func crossVenueSpreads(tops []venueTop) []crossSpread {
+	var spreads []crossSpread
+	for i, buy := range tops {
+		if buy.Ask.Price.Sign() <= 0 {
+			continue
+		}
+		for j, sell := range tops {
+			if i == j || sell.Bid.Price.Sign() <= 0 {
+				continue
+			}
+			ask := buy.Ask.Price.Float64()
+			spreads = append(spreads, crossSpread{
+				Buy:  buy.Venue,
+				Sell: sell.Venue,
+				Bps:  (sell.Bid.Price.Float64() - ask) / ask * 1e4,
+			})
+		}
+	}
+	return spreads
+}
+
+// Function to get the spread of a venue's own book in basis points of its mid price
+func (t venueTop) spreadBps() (float64, bool) {
+	if t.Bid.Price.Sign() <= 0 || t.Ask.Price.Sign() <= 0 {
+		return 0, false
+	}
+	mid := (t.Bid.Price.Float64() + t.Ask.Price.Float64()) / 2
+	return t.Ask.Price.Sub(t.Bid.Price).Float64() / mid * 1e4, true
+}
+
+// Function to format the venues as rows of bid, ask and spread, followed by the cross venue spreads.
+// A cross venue spread is highlighted when it is positive, as one venue's bid then exceeds another's ask.
+func getVenueRows(tops []venueTop) [][]string {
+	out := [][]string{{"Venue", "Bid", "Ask", "Spread"}}
+	for _, t := range tops {
+		bid, ask, spread := "n/a", "n/a", "n/a"
+		if t.Bid.Price.Sign() > 0 {
+			bid = fmt.Sprintf("[%s](%s)", t.Bid.Price.StringFixed(t.Places), theme.Up)
+		}
+		if t.Ask.Price.Sign() > 0 {
+			ask = fmt.Sprintf("[%s](%s)", t.Ask.Price.StringFixed(t.Places), theme.Down)
+		}
+		if bps, ok := t.spreadBps(); ok {
+			spread = fmt.Sprintf("%.2fbp", bps)
+		}
+		out = append(out, []string{t.Venue, bid, ask, spread})
+	}
+	for _, s := range crossVenueSpreads(tops) {
+		bps := fmt.Sprintf("%+.2fbp", s.Bps)
+		if s.Bps > 0 {
+			bps = fmt.Sprintf("[%s](%s)", bps, theme.Highlight)
+		}
+		out = append(out, []string{"buy " + s.Buy, "sell " + s.Sell, "", bps})
+	}
+	return out
+}
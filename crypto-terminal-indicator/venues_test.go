@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareVenuesOnlyMatchingSymbol(t *testing.T) {
+	venues := []*venue{
+		{name: "Kraken Futures", against: "btcusdt", market: newMarket("pf_xbtusd", nil, 10, defaultTickSize, nil)},
+		{name: "Binance Spot", against: "ethusdt", market: newMarket("ethusdt", nil, 10, defaultTickSize, nil)},
+		{name: "Binance Spot", against: "btcusdt", market: newMarket("btcusdt", nil, 10, defaultTickSize, nil)},
+	}
+	for _, c := range []struct {
+		symbol string
+		want   []string
+	}{
+		{"BTCUSDT", []string{"Binance Futures", "Kraken Futures", "Binance Spot"}},
+		// after switching symbols the BTC venues must not be compared with the ETH book
+		{"ETHUSDT", []string{"Binance Futures", "Binance Spot"}},
+		{"SOLUSDT", []string{"Binance Futures"}},
+	} {
+		m := &marketSnapshot{
+			Symbol:  c.symbol,
+			BestBid: OrderbookEntry{Price: dec(t, "2000"), Volume: dec(t, "1")},
+			BestAsk: OrderbookEntry{Price: dec(t, "2000.1"), Volume: dec(t, "1")},
+		}
+		var got []string
+		for _, top := range compareVenues("Binance Futures", m, venues) {
+			got = append(got, top.Venue)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: compared %v, want %v", c.symbol, got, c.want)
+		}
+	}
+	if got := mostCompared(venues); got != 2 {
+		t.Errorf("mostCompared = %d, want 2", got)
+	}
+}
+
+func TestParseVenuesRejectsUnknownSymbol(t *testing.T) {
+	if _, err := parseVenues("binance-spot:ethusdt@ethusdt", []string{"btcusdt"}, nil, 10, nil, 0); err == nil {
+		t.Error("parseVenues accepted a venue compared with a symbol which isn't subscribed")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+	"time"
+
+	ui "github.com/gizak/termui/v3"
+)
+
+// Structure representing everything the dashboard shows in a frame, formatted but not drawn yet.
+// It is built from a market snapshot without touching the terminal, so a frame can be checked without a TTY.
+// The plots are drawn straight from the snapshot, as they show series rather than text.
+type view struct {
+	Ticker    string
+	Price     string
+	Premium   string
+	Funding   string
+	Status    string
+	BookStats string
+	BookTitle string
+
+	Book         [][]string
+	Trades       [][]string
+	Flow         [][]string
+	Liquidations [][]string
+	Venues       [][]string // nil when no other venues are compared
+
+	Alerts string
+	Paper  string
+
+	Market   *marketSnapshot
+	Interval time.Duration // interval of the candles of the chart
+}
+
+// Structure representing the settings a view is built with, the sizes come from the layout of the dashboard
+type viewOptions struct {
+	Rows     int // number of ask and bid levels of the orderbook
+	TapeRows int // number of trades of the time & sales
+	LiqRows  int // number of liquidations
+	BarWidth int // width of the cumulative volume bars
+
+	Whale            float64
+	LargeLiquidation float64
+	Interval         time.Duration
+	Now              time.Time // time the funding countdown is computed at
+}
+
+// Function to build the view of a market snapshot.
+// The feed status, the alerts, the paper trading and the venues don't depend on the snapshot alone,
+// they are filled in by the caller.
+func buildView(m *marketSnapshot, opts viewOptions) *view {
+	v := &view{
+		Ticker:    fmt.Sprintf("[%s](%s)", m.Symbol, theme.Accent),
+		Price:     getMarketPrice(m),
+		Premium:   getPremiumText(m),
+		Funding:   getFundingText(m, opts.Now),
+		BookStats: getBookStats(m),
+		BookTitle: "Orderbook",
+
+		Book:         getBookRows(m, opts.Rows, opts.BarWidth, opts.Whale),
+		Trades:       getTradeRows(m, opts.TapeRows),
+		Flow:         getFlowRows(m),
+		Liquidations: getLiquidationRows(m, opts.LiqRows, opts.LargeLiquidation),
+
+		Market:   m,
+		Interval: opts.Interval,
+	}
+	if m.Bucket.Sign() > 0 {
+		v.BookTitle = fmt.Sprintf("Orderbook by %s", m.Bucket)
+	}
+	return v
+}
+
+// Function to get the market price of a symbol with arrow indicator
+func getMarketPrice(m *marketSnapshot) string {
+	places := m.TickSize.Places()
+	price := fmt.Sprintf("[%s %.*f](%s)", ARROW_UP, places, m.CurrMarkPrice, theme.Up)
+	if m.PrevMarkPrice > m.CurrMarkPrice {
+		price = fmt.Sprintf("[%s %.*f](%s)", ARROW_DOWN, places, m.CurrMarkPrice, theme.Down)
+	}
+	return price
+}
+
+// Function to get the options of the views of the dashboard, which depend on its current layout
+func (d *dashboard) viewOptions(whale, largeLiquidation float64, interval time.Duration, now time.Time) viewOptions {
+	return viewOptions{
+		Rows:             d.rows,
+		TapeRows:         d.tapeRows,
+		LiqRows:          d.liqRows,
+		BarWidth:         d.barWidth,
+		Whale:            whale,
+		LargeLiquidation: largeLiquidation,
+		Interval:         interval,
+		Now:              now,
+	}
+}
+
+// Function to fill the widgets of the dashboard with a view
+func (d *dashboard) apply(v *view) {
+	d.pticker.Text = v.Ticker
+	d.pprice.Text = v.Price
+	d.pindex.Text = v.Premium
+	d.pfund.Text = v.Funding
+	d.pstatus.Text = v.Status
+	d.pbook.Text = v.BookStats
+	d.tob.Title = v.BookTitle
+	d.tob.Rows = v.Book
+	d.tape.Rows = v.Trades
+	d.pflow.Rows = v.Flow
+	d.chart.update(v.Market, v.Interval)
+	if d.palerts != nil {
+		d.palerts.Text = v.Alerts
+	}
+	if d.ppaper != nil {
+		d.ppaper.Text = v.Paper
+	}
+	if d.pvenues != nil {
+		d.pvenues.Rows = v.Venues
+	}
+	if d.pliq != nil {
+		d.pliq.Rows = v.Liquidations
+		d.oi.update(v.Market)
+	}
+}
+
+// Function to draw the visible widgets into an off-screen buffer of the size of the screen.
+// Every widget is drawn into its own buffer first and clipped to its rectangle, like termui does on the terminal,
+// so a widget which overflows never draws over its neighbours.
+func (d *dashboard) draw() *ui.Buffer {
+	buf := ui.NewBuffer(image.Rect(0, 0, d.width, d.height))
+	for _, w := range d.visible {
+		wbuf := ui.NewBuffer(w.GetRect())
+		w.Lock()
+		w.Draw(wbuf)
+		w.Unlock()
+		for p, c := range wbuf.CellMap {
+			if p.In(wbuf.Rectangle) && p.In(buf.Rectangle) {
+				buf.SetCell(c, p)
+			}
+		}
+	}
+	return buf
+}
+
+// Function to put a frame drawn off-screen on the terminal
+func (d *dashboard) render() {
+	ui.Render(&frame{buf: d.draw()})
+}
+
+// Structure presenting an off-screen buffer as a termui drawable, so that it can be put on the terminal as a whole
+type frame struct {
+	sync.Mutex
+	buf *ui.Buffer
+}
+
+func (f *frame) GetRect() image.Rectangle { return f.buf.Rectangle }
+
+func (f *frame) SetRect(x1, y1, x2, y2 int) {}
+
+func (f *frame) Draw(buf *ui.Buffer) {
+	for p, c := range f.buf.CellMap {
+		buf.SetCell(c, p)
+	}
+}
+
+// Function to get the characters of a buffer as lines of text, without their styles and trailing spaces.
+// It is meant for comparing drawn frames with golden files.
+func bufferText(buf *ui.Buffer) string {
+	var sb strings.Builder
+	r := buf.Rectangle
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		line := make([]rune, 0, r.Dx())
+		for x := r.Min.X; x < r.Max.X; x++ {
+			line = append(line, buf.GetCell(image.Pt(x, y)).Rune)
+		}
+		sb.WriteString(strings.TrimRight(string(line), " "))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
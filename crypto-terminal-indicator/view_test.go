@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the view tests")
+
+// Time the fixed market snapshot is taken at
+var viewNow = time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+
+// Function to build a market snapshot which doesn't depend on the clock or on a feed
+func viewSnapshot(t *testing.T) *marketSnapshot {
+	tick := dec(t, "0.1")
+	m := &marketSnapshot{
+		Symbol:          "BTCUSDT",
+		TickSize:        tick,
+		CurrMarkPrice:   27123.4,
+		PrevMarkPrice:   27120,
+		IndexPrice:      27110.5,
+		FundingRate:     "0.00010000",
+		FundingInterval: 8 * time.Hour,
+		NextFundingTime: viewNow.Add(83 * time.Minute),
+		CumDelta:        -1.25,
+	}
+	best := dec(t, "27123.5")
+	for i := 0; i < 8; i++ {
+		volume := NewDecimal(int64(50000000 + i%3*70000000))
+		if i == 5 {
+			volume = dec(t, "12.5")
+		}
+		m.Asks = append(m.Asks, OrderbookEntry{Price: best.Add(tick.Mul(int64(i))), Volume: volume})
+		m.Bids = append(m.Bids, OrderbookEntry{Price: best.Sub(tick.Mul(int64(i + 1))), Volume: volume})
+	}
+	m.BestAsk, m.BestBid = m.Asks[0], m.Bids[0]
+	m.RawAsks, m.RawBids = m.Asks, m.Bids
+
+	for i := 0; i < 12; i++ {
+		m.Trades = append(m.Trades, TradeEvent{
+			Symbol:     "BTCUSDT",
+			Price:      best.Sub(tick.Mul(int64(i % 4))),
+			Volume:     NewDecimal(int64(1000000 + i*2500000)),
+			BuyerMaker: i%3 == 0,
+			Time:       viewNow.Add(-time.Duration(i) * 7 * time.Second),
+		})
+	}
+	for i := 0; i < 40; i++ {
+		open := 27000 + float64(i%7)*12.5 + float64(i)*2
+		m.Candles = append(m.Candles, Candle{
+			Start:  viewNow.Truncate(time.Minute).Add(time.Duration(i-39) * time.Minute),
+			Open:   open,
+			High:   open + 20,
+			Low:    open - 15,
+			Close:  open + float64(i%5-2)*4,
+			Volume: 10 + float64(i%9),
+		})
+	}
+	m.Flow = []flowStats{
+		{Window: time.Minute, VWAP: 27122.85, BuyVolume: 3.5, SellVolume: 4.75},
+		{Window: 5 * time.Minute, VWAP: 27101.2, BuyVolume: 21.25, SellVolume: 18},
+	}
+	m.Liquidations = []LiquidationEvent{
+		{Symbol: "BTCUSDT", Side: "sell", Price: dec(t, "27110"), Volume: dec(t, "2.5"), Time: viewNow.Add(-30 * time.Second)},
+		{Symbol: "BTCUSDT", Side: "buy", Price: dec(t, "27140.3"), Volume: dec(t, "0.02"), Time: viewNow.Add(-3 * time.Minute)},
+	}
+	for i := 0; i < 20; i++ {
+		m.OpenInterest = append(m.OpenInterest, openInterestSample{
+			Time:         viewNow.Add(time.Duration(i-19) * time.Minute),
+			OpenInterest: 81000 + float64(i*i)*3,
+			MarkPrice:    27000 + float64(i)*6,
+		})
+	}
+	return m
+}
+
+func TestViewGolden(t *testing.T) {
+	// the trade and liquidation times are shown in local time
+	local := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = local })
+	defaultTheme := theme
+	theme = themes["default"]
+	t.Cleanup(func() { theme = defaultTheme })
+
+	m := viewSnapshot(t)
+	for _, c := range []struct {
+		name          string
+		width, height int
+		perpetual     bool
+		extras        bool // alerts, paper trading and a compared venue
+	}{
+		{name: "perpetual", width: 160, height: 48, perpetual: true, extras: true},
+		{name: "spot", width: 120, height: 40},
+		{name: "small", width: 80, height: 24, perpetual: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			venues := 0
+			if c.extras {
+				venues = 1
+			}
+			d := newDashboard("Binance Futures", c.extras, len(m.Flow), c.extras, venues, c.perpetual, layoutConfig{}, nil)
+			d.layout(c.width, c.height, 20)
+
+			v := buildView(m, d.viewOptions(5, 1000, time.Minute, viewNow))
+			v.Status = "connected"
+			if c.extras {
+				v.Alerts = getAlertText([]Alert{{Time: viewNow.Add(-time.Minute), Text: "BTCUSDT: btc above 27k (mark_price 27123.4)"}}, false)
+				v.Paper = "flat"
+				v.Venues = getVenueRows([]venueTop{
+					topOfBook("Binance Futures", m),
+					{Venue: "Kraken Futures", Bid: OrderbookEntry{Price: dec(t, "27124")}, Ask: OrderbookEntry{Price: dec(t, "27124.5")}, Places: 1},
+				})
+			}
+			d.apply(v)
+			checkGolden(t, filepath.Join("testdata", "view_"+c.name+".golden"), bufferText(d.draw()))
+		})
+	}
+}
+
+// Function to compare text with a golden file, which is rewritten instead when the tests run with -update
+func checkGolden(t *testing.T, path, got string) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+	if got != string(want) {
+		gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+		for i := 0; i < len(gotLines) && i < len(wantLines); i++ {
+			if gotLines[i] != wantLines[i] {
+				t.Fatalf("%s differs at line %d\ngot:\n%s\nwant:\n%s", path, i+1, gotLines[i], wantLines[i])
+			}
+		}
+		t.Fatalf("%s has %d lines, got %d", path, len(wantLines), len(gotLines))
+	}
+}
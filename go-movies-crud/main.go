@@ -1,109 +1,252 @@
 package main
 
 import (
+	"context"       // for shutting the server down once it is interrupted
 	"encoding/json" // for encoding the data into json when sending it to postman
+	"errors"        // for checking if the store couldn't find a movie
+	"flag"          // for choosing where the movies are stored from the command line
 	"fmt"           // for printing
+	"io"            // for closing the stores which keep a file open
 	"log"           // for logging out data or error
-	"math/rand"     // for creating random 'id' for new movies which will be added by the user
 	"net/http"      // for creating server
-	"strconv"       // for converting the 'id'(i.e integer) generated by 'math/rand' into 'string'
+	"os"            // for the interrupt signal
+	"os/signal"     // for noticing when the server is stopped with Ctrl+C or by the system
+	"strconv"       // for reading the name of an unknown field out of the error of the decoder
+	"strings"       // for checking the message of the error of the decoder
+	"syscall"       // for the signal the system stops the server with
 
 	"github.com/gorilla/mux" // for routing
 )
 
+// the `validate` tags list the rules a field has to follow when a movie is sent to the server, see `rules`
 type Movie struct {
 	ID       string    `json:"id"`
-	Isbn     string    `json:"isbn"` // unique number assigned to the film
-	Title    string    `json:"title"`
-	Director *Director `json:"director"`
+	Isbn     string    `json:"isbn" validate:"required,isbn"` // unique number assigned to the film
+	Title    string    `json:"title" validate:"required,max=200"`
+	Director *Director `json:"director" validate:"required"`
 }
 
 type Director struct {
-	Firstname string `json:"firstname"`
-	Lastname  string `json:"lastname"`
+	Firstname string `json:"firstname" validate:"required,max=100"`
+	Lastname  string `json:"lastname" validate:"required,max=100"`
 }
 
-var movies []Movie
+// command line flags, by default the movies are kept in memory and lost on restart like before
+var (
+	storeflag = flag.String("store", "memory", "where the movies are kept: memory or file")
+	dataflag  = flag.String("data", "movies.json", "snapshot file of the file store, its log is kept next to it with a .log suffix")
+)
+
+// `server` holds the handlers, they only depend on the `MovieStore` interface and not on where the movies are kept
+type server struct {
+	store MovieStore
+}
 
-func getMovies(w http.ResponseWriter, r *http.Request) {
+func (s *server) getMovies(w http.ResponseWriter, r *http.Request) {
 	// here `r` is a pointer of request that we'll send from our postman to this function and
 	// `w` is the response writer which gives back the response from the server back to function or frontend
-	w.Header().Set("Content Type", "application/json")
-	json.NewEncoder(w).Encode(movies)
+	movies, err := s.store.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, movies)
 }
 
-func deleteMovie(w http.ResponseWriter, r *http.Request) {
-	// set json content type
-	w.Header().Set("Content Type", "application/json")
+func (s *server) deleteMovie(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r) // here params is the `ID` that we pass from Postman will go as params to our function
-	// loop over the movies, range
 	// delete the movie with the ID that you've sent
-	for index, item := range movies {
-
-		if item.ID == params["id"] {
-			// below we will see how we can delete a movie using `append()`
-			movies = append(movies[:index], movies[index+1:]...)
-			// above we are appending rest of the `movies` in place of the given `movie` in this way we are removing the given movie from the list
-			break
-		}
+	if err := s.store.Delete(params["id"]); err != nil {
+		writeError(w, storeStatus(err), err)
+		return
 	}
-	json.NewEncoder(w).Encode(movies)
+	// 204 means it worked and there is nothing to send back
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func getMovie(w http.ResponseWriter, r *http.Request) {
-	// set json content type
-	w.Header().Set("Content Type", "application/json")
+func (s *server) getMovie(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
-	for _, item := range movies {
-		if item.ID == params["id"] {
-			json.NewEncoder(w).Encode(item)
-			return
-		}
+	movie, err := s.store.Get(params["id"])
+	if err != nil {
+		writeError(w, storeStatus(err), err)
+		return
 	}
+	writeJSON(w, http.StatusOK, movie)
 }
 
-func createMovie(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content Type", "application/json")
-	var movie Movie
-	_ = json.NewDecoder(r.Body).Decode(&movie)
-	movie.ID = strconv.Itoa(rand.Intn(10000000))
-	movies = append(movies, movie)
-	json.NewEncoder(w).Encode(movie)
+func (s *server) createMovie(w http.ResponseWriter, r *http.Request) {
+	movie, err := decodeMovie(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// the store gives the movie its ID, any ID sent in the body is ignored
+	movie, err = s.store.Create(movie)
+	if err != nil {
+		writeError(w, storeStatus(err), err)
+		return
+	}
+	// 201 means a new movie was created, the `Location` header tells where it can be found
+	w.Header().Set("Location", "/movies/"+movie.ID)
+	writeJSON(w, http.StatusCreated, movie)
 }
 
-func updateMovie(w http.ResponseWriter, r *http.Request) {
-	// set json content type
-	w.Header().Set("Content Type", "application/json")
+func (s *server) updateMovie(w http.ResponseWriter, r *http.Request) {
 	// params
 	params := mux.Vars(r)
-	// loop over the movies, range
-	// delete the movie with the ID that you've sent
-	// add a new movie(i.e the movie that we sent in the body of the postman)
-	for index, item := range movies {
-		if item.ID == params["id"] {
-			// deleting
-			movies = append(movies[:index], movies[index+1:]...)
-			var movie Movie
-			_ = json.NewDecoder(r.Body).Decode(&movie)
-			// adding
-			movie.ID = params["id"]
-			movies = append(movies, movie)
-			json.NewEncoder(w).Encode(movie)
-		}
+	// replace the movie with the ID that you've sent by the movie that we sent in the body of the postman
+	movie, err := decodeMovie(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
 	}
+	movie.ID = params["id"]
+	if err := s.store.Update(params["id"], movie); err != nil {
+		writeError(w, storeStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, movie)
 }
 
-func main() {
+// reads the movie sent in the body of the request and checks it against the `validate` tags,
+// fields which a `Movie` doesn't have are rejected so that a typo in a field name doesn't go unnoticed
+func decodeMovie(r *http.Request) (Movie, error) {
+	var movie Movie
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&movie); err != nil {
+		return movie, decodeError("movie", err)
+	}
+	return movie, validate("movie", movie)
+}
+
+// turns an error of the json decoder into a `validationError`, listing the field it is about when there is one
+func decodeError(name string, err error) error {
+	verr := &validationError{Message: "invalid " + name, Fields: []fieldError{}}
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		verr.Fields = append(verr.Fields, fieldError{Field: typeErr.Field, Message: "must not be a " + typeErr.Value})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// the decoder has no error type for unknown fields, the field is quoted at the end of the message
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		verr.Fields = append(verr.Fields, fieldError{Field: field, Message: "is not a field of a " + name})
+	default:
+		verr.Message += ": " + err.Error()
+	}
+	return verr
+}
+
+// sends `v` as json with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	// set json content type, it has to be set before the status code is written
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
+
+// sends an error as json, e.g. {"error": "movie not found"}.
+// A `validationError` is sent as it is, so the problem of every field is listed.
+func writeError(w http.ResponseWriter, status int, err error) {
+	var verr *validationError
+	if errors.As(err, &verr) {
+		writeJSON(w, status, verr)
+		return
+	}
+	// a duplicate ISBN is a problem of the isbn field as well
+	if errors.Is(err, ErrDuplicateIsbn) {
+		writeJSON(w, status, &validationError{
+			Message: "invalid movie",
+			Fields:  []fieldError{{Field: "isbn", Message: "is already used by another movie"}},
+		})
+		return
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// returns the status code of an error of the store, 404 when the movie doesn't exist
+// and 409 when its ISBN clashes with another movie
+func storeStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrDuplicateIsbn):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// builds the routes of the server
+func (s *server) routes() *mux.Router {
 	r := mux.NewRouter()
-	// movies slices
-	movies = append(movies, Movie{ID: "1", Isbn: "348738", Title: "Movie One", Director: &Director{Firstname: "John", Lastname: "Doe"}}) // `&` is to get the address and `*` is used to access that address or the pointer
-	movies = append(movies, Movie{ID: "2", Isbn: "328746", Title: "Movie Two", Director: &Director{Firstname: "Steve", Lastname: "Smith"}})
-	r.HandleFunc("/movies", getMovies).Methods("GET")
-	r.HandleFunc("/movies/{id}", getMovie).Methods("GET")
-	r.HandleFunc("/movies", createMovie).Methods("POST")
-	r.HandleFunc("/movies/{id}", updateMovie).Methods("PUT")
-	r.HandleFunc("/movies/{id}", deleteMovie).Methods("DELETE")
+	r.HandleFunc("/movies", s.getMovies).Methods("GET")
+	r.HandleFunc("/movies/{id}", s.getMovie).Methods("GET")
+	r.HandleFunc("/movies", s.createMovie).Methods("POST")
+	r.HandleFunc("/movies/{id}", s.updateMovie).Methods("PUT")
+	r.HandleFunc("/movies/{id}", s.deleteMovie).Methods("DELETE")
+	return r
+}
+
+// opens the store chosen with the `--store` flag
+func openStore(kind, path string) (MovieStore, error) {
+	switch kind {
+	case "memory":
+		return newMemoryStore(), nil
+	case "file":
+		return openFileStore(path)
+	}
+	return nil, fmt.Errorf("unknown store %q, expected memory or file", kind)
+}
+
+func main() {
+	flag.Parse()
+	store, err := openStore(*storeflag, *dataflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the example movies are only added to an empty store, so restarting with the file store doesn't add them twice
+	movies, err := store.List()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(movies) == 0 {
+		for _, movie := range []Movie{
+			{Isbn: "9780306406157", Title: "Movie One", Director: &Director{Firstname: "John", Lastname: "Doe"}}, // `&` is to get the address and `*` is used to access that address or the pointer
+			{Isbn: "9781861972712", Title: "Movie Two", Director: &Director{Firstname: "Steve", Lastname: "Smith"}},
+		} {
+			if _, err := store.Create(movie); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	s := &server{store: store}
+	srv := &http.Server{Addr: ":8000", Handler: s.routes()}
+
+	// when the server is stopped it waits for the running requests before the store is closed,
+	// so no change is cut off halfway
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Printf("shutting down: %v", err)
+		}
+		close(done)
+	}()
 
 	fmt.Printf("Starting server at port 8000\n")
-	log.Fatal(http.ListenAndServe(":8000", r))
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-done
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
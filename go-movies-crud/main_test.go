@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sends a request to the routes of a server and returns the recorded response
+func do(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// returns the body of a movie with the given ISBN as it is sent by a client
+func movieBody(isbn, title string) string {
+	return `{"isbn":"` + isbn + `","title":"` + title + `","director":{"firstname":"John","lastname":"Doe"}}`
+}
+
+// creates a movie through the routes and returns it as the server sent it back
+func createMovie(t *testing.T, h http.Handler, isbn, title string) Movie {
+	t.Helper()
+	rec := do(t, h, "POST", "/movies", movieBody(isbn, title))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /movies = %d %s, want 201", rec.Code, rec.Body)
+	}
+	var movie Movie
+	if err := json.Unmarshal(rec.Body.Bytes(), &movie); err != nil {
+		t.Fatal(err)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/movies/"+movie.ID {
+		t.Errorf("Location = %q, want /movies/%s", loc, movie.ID)
+	}
+	return movie
+}
+
+// returns the movies listed by the routes
+func listMovies(t *testing.T, h http.Handler) []Movie {
+	t.Helper()
+	rec := do(t, h, "GET", "/movies", "")
+	var movies []Movie
+	if err := json.Unmarshal(rec.Body.Bytes(), &movies); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET /movies = %d %s, %v", rec.Code, rec.Body, err)
+	}
+	return movies
+}
+
+func TestCreateMovie(t *testing.T) {
+	h := (&server{store: newMemoryStore()}).routes()
+	movie := createMovie(t, h, "9780306406157", "Movie One")
+	if movie.ID != "1" || movie.Title != "Movie One" || movie.Director == nil || movie.Director.Lastname != "Doe" {
+		t.Errorf("created %+v", movie)
+	}
+
+	rec := do(t, h, "GET", "/movies/"+movie.ID, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /movies/%s = %d %s", movie.ID, rec.Code, rec.Body)
+	}
+	var got Movie
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got.Isbn != movie.Isbn {
+		t.Errorf("GET /movies/%s = %s, %v", movie.ID, rec.Body, err)
+	}
+}
+
+func TestUnknownMovie(t *testing.T) {
+	h := (&server{store: newMemoryStore()}).routes()
+	createMovie(t, h, "9780306406157", "Movie One")
+	for _, c := range []struct {
+		method, body string
+	}{
+		{"GET", ""},
+		{"PUT", movieBody("9781861972712", "Movie Two")},
+		{"DELETE", ""},
+	} {
+		rec := do(t, h, c.method, "/movies/42", c.body)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s /movies/42 = %d %s, want 404", c.method, rec.Code, rec.Body)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] != ErrNotFound.Error() {
+			t.Errorf("%s /movies/42 body = %s", c.method, rec.Body)
+		}
+	}
+}
+
+func TestUndecodableBody(t *testing.T) {
+	h := (&server{store: newMemoryStore()}).routes()
+	movie := createMovie(t, h, "9780306406157", "Movie One")
+	for _, c := range []struct {
+		name, method, path, body string
+	}{
+		{"not json", "POST", "/movies", "{"},
+		{"empty", "POST", "/movies", ""},
+		{"wrong type", "POST", "/movies", `{"isbn":9780306406157,"title":"Movie Two"}`},
+		{"unknown field", "POST", "/movies", `{"isbn":"9781861972712","title":"Movie Two","year":1999}`},
+		{"update not json", "PUT", "/movies/" + movie.ID, "not a movie"},
+	} {
+		rec := do(t, h, c.method, c.path, c.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %s %s = %d %s, want 400", c.name, c.method, c.path, rec.Code, rec.Body)
+		}
+	}
+	// nothing was created or changed by the rejected requests
+	if movies := listMovies(t, h); len(movies) != 1 || movies[0].Title != "Movie One" {
+		t.Errorf("movies = %+v", movies)
+	}
+}
+
+func TestDeleteMovie(t *testing.T) {
+	h := (&server{store: newMemoryStore()}).routes()
+	one := createMovie(t, h, "9780306406157", "Movie One")
+	two := createMovie(t, h, "9781861972712", "Movie Two")
+
+	rec := do(t, h, "DELETE", "/movies/"+two.ID, "")
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Fatalf("DELETE /movies/%s = %d %q, want 204 without a body", two.ID, rec.Code, rec.Body)
+	}
+	if rec := do(t, h, "GET", "/movies/"+two.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of the deleted movie = %d, want 404", rec.Code)
+	}
+	if rec := do(t, h, "DELETE", "/movies/"+two.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second DELETE = %d, want 404", rec.Code)
+	}
+
+	// the ID of the deleted movie, which was the highest one, is not given out again
+	three := createMovie(t, h, "9781861972712", "Movie Three")
+	if three.ID == one.ID || three.ID == two.ID {
+		t.Errorf("new movie got the ID %s again", three.ID)
+	}
+	if rec := do(t, h, "GET", "/movies/"+two.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of the deleted ID after a create = %d, want 404", rec.Code)
+	}
+}
+
+func TestInvalidMovieFields(t *testing.T) {
+	h := (&server{store: newMemoryStore()}).routes()
+	for _, c := range []struct {
+		name, body string
+		fields     []fieldError
+	}{
+		{
+			name:   "no director",
+			body:   `{"isbn":"9780306406157","title":"Movie One"}`,
+			fields: []fieldError{{"director", "is required"}},
+		},
+		{
+			name:   "null director",
+			body:   `{"isbn":"9780306406157","title":"Movie One","director":null}`,
+			fields: []fieldError{{"director", "is required"}},
+		},
+		{
+			name: "several fields",
+			body: `{"isbn":"9780306406158","title":"","director":{"firstname":"","lastname":"Doe"}}`,
+			fields: []fieldError{
+				{"isbn", "must be a valid ISBN-10 or ISBN-13"},
+				{"title", "is required"},
+				{"director.firstname", "is required"},
+			},
+		},
+	} {
+		rec := do(t, h, "POST", "/movies", c.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: POST /movies = %d %s, want 400", c.name, rec.Code, rec.Body)
+			continue
+		}
+		var body validationError
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Message != "invalid movie" || !reflect.DeepEqual(body.Fields, c.fields) {
+			t.Errorf("%s: body = %s, want the fields %+v", c.name, rec.Body, c.fields)
+		}
+	}
+	if movies := listMovies(t, h); len(movies) != 0 {
+		t.Errorf("invalid movies were created: %+v", movies)
+	}
+}
+
+func TestDuplicateIsbn(t *testing.T) {
+	h := (&server{store: newMemoryStore()}).routes()
+	one := createMovie(t, h, "9780306406157", "Movie One")
+	two := createMovie(t, h, "9781861972712", "Movie Two")
+
+	duplicate := []fieldError{{"isbn", "is already used by another movie"}}
+	for _, c := range []struct {
+		name, method, path, body string
+	}{
+		{"same isbn", "POST", "/movies", movieBody("9780306406157", "Movie Three")},
+		// the ISBN-10 and the hyphenated ISBN-13 of the first movie are the same book
+		{"isbn-10 of the same book", "POST", "/movies", movieBody("0306406152", "Movie Three")},
+		{"hyphenated isbn", "POST", "/movies", movieBody("978-0-306-40615-7", "Movie Three")},
+		{"update to another movie's isbn", "PUT", "/movies/" + two.ID, movieBody("0-306-40615-2", "Movie Two")},
+	} {
+		rec := do(t, h, c.method, c.path, c.body)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("%s: %s %s = %d %s, want 409", c.name, c.method, c.path, rec.Code, rec.Body)
+			continue
+		}
+		var body validationError
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || !reflect.DeepEqual(body.Fields, duplicate) {
+			t.Errorf("%s: body = %s, %v", c.name, rec.Body, err)
+		}
+	}
+
+	// a movie keeps its own ISBN when it is updated, even written as its ISBN-10
+	if rec := do(t, h, "PUT", "/movies/"+one.ID, movieBody("0306406152", "Movie One Renamed")); rec.Code != http.StatusOK {
+		t.Errorf("PUT of a movie with its own ISBN = %d %s, want 200", rec.Code, rec.Body)
+	}
+	if movies := listMovies(t, h); len(movies) != 2 || movies[1].Isbn != "9781861972712" {
+		t.Errorf("movies = %+v", movies)
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bufio"         // for reading the log line by line
+	"encoding/json" // for writing the movies and the log entries to the files
+	"errors"        // for the error returned when a movie doesn't exist
+	"fmt"           // for adding context to the errors
+	"io"            // for rewinding the log after it is truncated
+	"log"           // for reporting a failed compaction, the change itself is saved by then
+	"os"            // for reading and writing the files
+	"path/filepath" // for creating the temporary snapshot next to the real one
+	"strconv"       // for turning the sequence number of a movie into its ID
+	"sync"          // for the mutex which protects the movies from concurrent handlers
+)
+
+// `ErrNotFound` is returned by a `MovieStore` when there is no movie with the given ID
+var ErrNotFound = errors.New("movie not found")
+
+// `MovieStore` is where the handlers keep the movies, the handlers only talk to this interface
+// so the movies can live in memory or in a file without changing them
+type MovieStore interface {
+	// returns all the movies in the order they were created
+	List() ([]Movie, error)
+	// returns the movie with the given ID or `ErrNotFound`
+	Get(id string) (Movie, error)
+	// adds a new movie under the next ID of the sequence and returns it with that ID,
+	// or returns `ErrDuplicateIsbn` when another movie has the same ISBN
+	Create(movie Movie) (Movie, error)
+	// replaces the movie with the given ID or returns `ErrNotFound`,
+	// or returns `ErrDuplicateIsbn` when another movie has the same ISBN
+	Update(id string, movie Movie) error
+	// removes the movie with the given ID or returns `ErrNotFound`
+	Delete(id string) error
+}
+
+// `memoryStore` keeps the movies in a slice, the mutex makes it safe to use from concurrent handlers
+// because `net/http` runs every request in its own goroutine.
+// The IDs are a sequence which never goes back, so the ID of a deleted movie is never given to another one.
+type memoryStore struct {
+	mu     sync.RWMutex
+	movies []Movie
+	lastID int64 // the highest ID given out so far
+}
+
+// `newMemoryStore()` is a constructor function for creating a new instance of `memoryStore` struct
+func newMemoryStore() *memoryStore {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) List() ([]Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// a copy is returned so the caller can't change our slice after the lock is released
+	return append([]Movie{}, s.movies...), nil
+}
+
+func (s *memoryStore) Get(id string) (Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if index := s.find(id); index >= 0 {
+		return s.movies[index], nil
+	}
+	return Movie{}, ErrNotFound
+}
+
+func (s *memoryStore) Create(movie Movie) (Movie, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isbnTaken(movie.Isbn, "") {
+		return Movie{}, ErrDuplicateIsbn
+	}
+	movie.ID = strconv.FormatInt(s.lastID+1, 10)
+	s.add(movie)
+	return movie, nil
+}
+
+// adds a movie which already has its ID, the sequence moves past the ID so it isn't given out again
+func (s *memoryStore) insert(movie Movie) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(movie)
+}
+
+// returns the ID the next created movie will get
+func (s *memoryStore) nextID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return strconv.FormatInt(s.lastID+1, 10)
+}
+
+// adds a movie and moves the sequence past its ID, the caller must hold the lock
+func (s *memoryStore) add(movie Movie) {
+	s.movies = append(s.movies, movie)
+	if id, err := strconv.ParseInt(movie.ID, 10, 64); err == nil && id > s.lastID {
+		s.lastID = id
+	}
+}
+
+func (s *memoryStore) Update(id string, movie Movie) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.find(id)
+	if index < 0 {
+		return ErrNotFound
+	}
+	if s.isbnTaken(movie.Isbn, id) {
+		return ErrDuplicateIsbn
+	}
+	s.movies[index] = movie
+	return nil
+}
+
+func (s *memoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.find(id)
+	if index < 0 {
+		return ErrNotFound
+	}
+	// same trick as before, appending the rest of the `movies` in place of the deleted one
+	s.movies = append(s.movies[:index], s.movies[index+1:]...)
+	return nil
+}
+
+// applies a change which was already checked, like the changes read back from the log of the `fileStore`.
+// A create of an ID which exists, or an update or delete of one which doesn't, finds the change applied already
+// and does nothing, so applying the same changes twice leaves the same movies.
+func (s *memoryStore) replay(op, id string, movie *Movie) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.find(id)
+	switch op {
+	case "create":
+		if index < 0 {
+			s.add(*movie)
+		}
+	case "update":
+		if index >= 0 {
+			s.movies[index] = *movie
+		}
+	case "delete":
+		if index >= 0 {
+			s.movies = append(s.movies[:index], s.movies[index+1:]...)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", op)
+	}
+	return nil
+}
+
+// checks the changed movie against the others without changing anything, it returns the error the change would fail with
+func (s *memoryStore) check(op, id string, movie *Movie) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if op != "create" && s.find(id) < 0 {
+		return ErrNotFound
+	}
+	if movie != nil && s.isbnTaken(movie.Isbn, id) {
+		return ErrDuplicateIsbn
+	}
+	return nil
+}
+
+// reports if a movie other than the one with the ID `except` has the same ISBN, the caller must hold the lock.
+// The ISBNs are compared in their ISBN-13 form and movies without an ISBN never clash.
+func (s *memoryStore) isbnTaken(isbn, except string) bool {
+	if isbn == "" {
+		return false
+	}
+	isbn = canonicalIsbn(isbn)
+	for _, item := range s.movies {
+		if item.ID != except && canonicalIsbn(item.Isbn) == isbn {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the index of the movie with the given ID or -1, the caller must hold the lock
+func (s *memoryStore) find(id string) int {
+	for index, item := range s.movies {
+		if item.ID == id {
+			return index
+		}
+	}
+	return -1
+}
+
+// `snapshot` is the content of the snapshot file of the `fileStore`
+type snapshot struct {
+	// the sequence is saved as well, as the movie with the highest ID may have been deleted
+	LastID int64   `json:"last_id"`
+	Movies []Movie `json:"movies"`
+}
+
+// `logEntry` is one line of the append-only log of the `fileStore`
+type logEntry struct {
+	Op    string `json:"op"` // one of "create", "update" or "delete"
+	ID    string `json:"id"`
+	Movie *Movie `json:"movie,omitempty"` // not set for "delete"
+}
+
+// `fileStore` keeps the movies in memory and saves them to disk so they survive a restart.
+// The movies are saved as a JSON snapshot plus an append-only log of the changes made since,
+// so a change only has to append one line instead of rewriting the whole file.
+// When the store is opened, and every `compactAfter` changes while it is running, the log is compacted into a new snapshot.
+type fileStore struct {
+	*memoryStore
+	mu           sync.Mutex // serializes the changes so the log has the same order as the memory
+	snapshot     string
+	log          *os.File
+	entries      int // number of entries in the log
+	compactAfter int // number of entries after which the log is compacted
+}
+
+// number of changes the log of a `fileStore` holds before it is compacted into a new snapshot
+const defaultCompactAfter = 1000
+
+// `openFileStore()` is a constructor function for creating a new instance of `fileStore` struct.
+// The snapshot is kept at `path` and the log at `path` + ".log", both are created when they don't exist.
+func openFileStore(path string) (*fileStore, error) {
+	s := &fileStore{memoryStore: newMemoryStore(), snapshot: path, compactAfter: defaultCompactAfter}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(s.logPath(), os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.log = file
+	// everything from the log is in the memory now, so it is written to a fresh snapshot and the log starts over
+	if err := s.rotate(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileStore) logPath() string {
+	return s.snapshot + ".log"
+}
+
+// reads the snapshot and replays the log on top of it
+func (s *fileStore) load() error {
+	data, err := os.ReadFile(s.snapshot)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		var snap snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return fmt.Errorf("snapshot %s: %w", s.snapshot, err)
+		}
+		for _, movie := range snap.Movies {
+			s.memoryStore.insert(movie)
+		}
+		if snap.LastID > s.memoryStore.lastID {
+			s.memoryStore.lastID = snap.LastID
+		}
+	}
+
+	file, err := os.Open(s.logPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	line := 0
+	// a crash in the middle of a write can only cut off the last line, so a line which can't be decoded
+	// is only an error when another line follows it
+	var truncated error
+	for scanner.Scan() {
+		line++
+		if truncated != nil {
+			return truncated
+		}
+		var entry logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			truncated = fmt.Errorf("log %s: line %d: %w", s.logPath(), line, err)
+			continue
+		}
+		if err := s.apply(entry); err != nil {
+			return fmt.Errorf("log %s: line %d: %w", s.logPath(), line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// applies a log entry to the movies in memory.
+// A crash between writing a snapshot and truncating the log leaves a log whose entries are in the snapshot already,
+// so an entry which finds its change applied is skipped instead of failing the start of the server.
+func (s *fileStore) apply(entry logEntry) error {
+	if entry.Op != "delete" && entry.Movie == nil {
+		return fmt.Errorf("%s of %q without a movie", entry.Op, entry.ID)
+	}
+	return s.memoryStore.replay(entry.Op, entry.ID, entry.Movie)
+}
+
+// writes all the movies to a new snapshot, it is written to a temporary file first
+// and renamed over the old one so a crash never leaves half a snapshot behind
+func (s *fileStore) compact() error {
+	s.memoryStore.mu.RLock()
+	snap := snapshot{LastID: s.memoryStore.lastID, Movies: s.memoryStore.movies}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	s.memoryStore.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.snapshot), filepath.Base(s.snapshot)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.snapshot)
+}
+
+// writes a new snapshot and starts the log over, the caller must hold the lock of the changes unless the store is being opened
+func (s *fileStore) rotate() error {
+	if err := s.compact(); err != nil {
+		return err
+	}
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+	// the file isn't opened for appending, so the next entry would be written at the old end without this
+	if _, err := s.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.entries = 0
+	return nil
+}
+
+// appends an entry to the log and applies it to the memory, the entry is synced to disk
+// before the change is applied so a movie is never reported as saved without being on disk
+func (s *fileStore) write(entry logEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.Op == "create" {
+		entry.ID = s.memoryStore.nextID()
+		entry.Movie.ID = entry.ID
+	}
+	// the entry is checked against the memory first, so the log never holds a change which failed
+	if err := s.memoryStore.check(entry.Op, entry.ID, entry.Movie); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	if err := s.apply(entry); err != nil {
+		return err
+	}
+	// the log would grow for as long as the server runs, it is compacted once it holds enough entries.
+	// The change is saved either way, so a failed compaction is only reported and tried again with the next change.
+	s.entries++
+	if s.entries >= s.compactAfter {
+		if err := s.rotate(); err != nil {
+			log.Printf("compacting %s: %v", s.logPath(), err)
+		}
+	}
+	return nil
+}
+
+// the ID is taken from the sequence under the lock of `write`, so two concurrent creates can't get the same one
+func (s *fileStore) Create(movie Movie) (Movie, error) {
+	err := s.write(logEntry{Op: "create", Movie: &movie})
+	return movie, err
+}
+
+func (s *fileStore) Update(id string, movie Movie) error {
+	return s.write(logEntry{Op: "update", ID: id, Movie: &movie})
+}
+
+func (s *fileStore) Delete(id string) error {
+	return s.write(logEntry{Op: "delete", ID: id})
+}
+
+// closes the log, the changes are already on disk so nothing else has to be written.
+// It waits for a running change, so it is safe to call while requests are still handled.
+func (s *fileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// returns a valid movie with the given ISBN
+func testMovie(isbn, title string) Movie {
+	return Movie{Isbn: isbn, Title: title, Director: &Director{Firstname: "John", Lastname: "Doe"}}
+}
+
+// opens a file store at `path`, it is closed when the test ends
+func openTestStore(t *testing.T, path string) *fileStore {
+	t.Helper()
+	s, err := openFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// fills a store with changes of every kind, the last movie is deleted so the sequence is ahead of the movies
+func fillStore(t *testing.T, s MovieStore) []Movie {
+	t.Helper()
+	one, err := s.Create(testMovie("9780306406157", "Movie One"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	two, err := s.Create(testMovie("9781861972712", "Movie Two"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	three, err := s.Create(testMovie("9780131103627", "Movie Three"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the ISBN of the first movie moves to the second one, which only works in this order
+	one.Isbn = "9780596520687"
+	if err := s.Update(one.ID, one); err != nil {
+		t.Fatal(err)
+	}
+	two.Isbn = "9780306406157"
+	if err := s.Update(two.ID, two); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(three.ID); err != nil {
+		t.Fatal(err)
+	}
+	return []Movie{one, two}
+}
+
+func checkMovies(t *testing.T, s MovieStore, want []Movie) {
+	t.Helper()
+	got, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("movies = %+v, want %+v", got, want)
+	}
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movies.json")
+	s, err := openFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fillStore(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openTestStore(t, path)
+	checkMovies(t, s, want)
+	// the deleted movie had ID 3, it is never given out again
+	if movie, err := s.Create(testMovie("9780131103627", "Movie Four")); err != nil || movie.ID != "4" {
+		t.Errorf("Create = %+v, %v, want ID 4", movie, err)
+	}
+}
+
+// A crash between writing the snapshot and truncating the log leaves the snapshot and the full log behind,
+// the log is replayed on top of a snapshot which holds all of its changes already
+func TestFileStoreCrashAfterCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movies.json")
+	s, err := openFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fillStore(t, s)
+	if err := s.compact(); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if data, err := os.ReadFile(s.logPath()); err != nil || bytes.Count(data, []byte("\n")) != 6 {
+		t.Fatalf("log = %q, %v, want the 6 changes", data, err)
+	}
+
+	s = openTestStore(t, path)
+	checkMovies(t, s, want)
+	if movie, err := s.Create(testMovie("9780131103627", "Movie Four")); err != nil || movie.ID != "4" {
+		t.Errorf("Create = %+v, %v, want ID 4", movie, err)
+	}
+}
+
+func TestFileStoreCompactsWhileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movies.json")
+	s, err := openFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.compactAfter = 4
+	want := fillStore(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 6 changes were made, the log was compacted after the 4th and holds the last 2
+	data, err := os.ReadFile(s.logPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 2 || data[0] != '{' {
+		t.Errorf("log has %d entries, want 2 from its start:\n%q", lines, data)
+	}
+	checkMovies(t, openTestStore(t, path), want)
+}
+
+// returns a valid ISBN-13 for the number `n`, so every concurrent create gets its own ISBN
+func numberedIsbn(n int) string {
+	isbn := fmt.Sprintf("979%09d", n)
+	sum := 0
+	for i, c := range isbn {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(c-'0') * weight
+	}
+	return isbn + strconv.Itoa((10-sum%10)%10)
+}
+
+// Handlers run concurrently, every store must keep its movies and sequence consistent when they do
+func TestStoresConcurrentHandlers(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		store func(t *testing.T) MovieStore
+	}{
+		{"memory", func(t *testing.T) MovieStore { return newMemoryStore() }},
+		{"file", func(t *testing.T) MovieStore {
+			s := openTestStore(t, filepath.Join(t.TempDir(), "movies.json"))
+			s.compactAfter = 25 // the log is compacted while the other handlers run
+			return s
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			store := c.store(t)
+			h := (&server{store: store}).routes()
+			const workers, perWorker = 8, 20
+			var wg sync.WaitGroup
+			ids := make(chan string, workers*perWorker)
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < perWorker; i++ {
+						n := w*perWorker + i
+						rec := do(t, h, "POST", "/movies", movieBody(numberedIsbn(n), fmt.Sprintf("Movie %d", n)))
+						if rec.Code != http.StatusCreated {
+							t.Errorf("POST /movies = %d %s", rec.Code, rec.Body)
+							return
+						}
+						var movie Movie
+						json.Unmarshal(rec.Body.Bytes(), &movie)
+						ids <- movie.ID
+						// every other movie is renamed and every third one deleted, while the others list the movies
+						if i%2 == 0 {
+							if rec := do(t, h, "PUT", "/movies/"+movie.ID, movieBody(numberedIsbn(n), "Renamed")); rec.Code != http.StatusOK {
+								t.Errorf("PUT /movies/%s = %d %s", movie.ID, rec.Code, rec.Body)
+							}
+						}
+						if i%3 == 0 {
+							if rec := do(t, h, "DELETE", "/movies/"+movie.ID, ""); rec.Code != http.StatusNoContent {
+								t.Errorf("DELETE /movies/%s = %d %s", movie.ID, rec.Code, rec.Body)
+							}
+						}
+						do(t, h, "GET", "/movies", "")
+					}
+				}(w)
+			}
+			wg.Wait()
+			close(ids)
+
+			seen := make(map[string]bool)
+			for id := range ids {
+				if seen[id] {
+					t.Errorf("ID %s was given out twice", id)
+				}
+				seen[id] = true
+			}
+			movies, err := store.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			deleted := workers * ((perWorker + 2) / 3)
+			if len(movies) != workers*perWorker-deleted {
+				t.Errorf("%d movies left, want %d", len(movies), workers*perWorker-deleted)
+			}
+			// the file store reads back what the concurrent changes and compactions left on disk
+			if fs, ok := store.(*fileStore); ok {
+				if err := fs.Close(); err != nil {
+					t.Fatal(err)
+				}
+				checkMovies(t, openTestStore(t, fs.snapshot), movies)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"  // for the error returned when an ISBN is already used
+	"fmt"     // for building the messages of the field problems
+	"reflect" // for reading the `validate` tags of the structs
+	"strconv" // for parsing the limits of the rules
+	"strings" // for splitting the rules and cleaning up the ISBNs
+)
+
+// `ErrDuplicateIsbn` is returned by a `MovieStore` when another movie already has the same ISBN
+var ErrDuplicateIsbn = errors.New("isbn is already used by another movie")
+
+// `fieldError` is one problem with one field of the body, `Field` is the json name of the field
+// and nested fields are joined with a dot, e.g. "director.firstname"
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// `validationError` lists every problem found in a body, it is sent back as
+//
+//	{"error": "invalid movie", "fields": [{"field": "title", "message": "is required"}]}
+type validationError struct {
+	Message string       `json:"error"`
+	Fields  []fieldError `json:"fields"`
+}
+
+func (e *validationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		problems[i] = f.Field + " " + f.Message
+	}
+	return e.Message + ": " + strings.Join(problems, ", ")
+}
+
+// the rules which can be used in a `validate` tag, a rule gets the value of the field and its argument,
+// e.g. "max=200" calls the "max" rule with "200", and returns the problem or "" when there is none
+var rules = map[string]func(v reflect.Value, arg string) string{
+	// the field must not be empty, for a pointer it must not be nil
+	"required": func(v reflect.Value, arg string) string {
+		if v.IsZero() || (v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "") {
+			return "is required"
+		}
+		return ""
+	},
+	// a string must not be longer than `arg` characters
+	"max": func(v reflect.Value, arg string) string {
+		max, _ := strconv.Atoi(arg)
+		if len([]rune(v.String())) > max {
+			return fmt.Sprintf("must be at most %d characters", max)
+		}
+		return ""
+	},
+	// a string must be an ISBN-10 or an ISBN-13 with a correct check digit
+	"isbn": func(v reflect.Value, arg string) string {
+		if v.String() != "" && !validIsbn(v.String()) {
+			return "must be a valid ISBN-10 or ISBN-13"
+		}
+		return ""
+	},
+}
+
+// checks a struct against the `validate` tags of its fields, e.g. `validate:"required,max=200"`.
+// Pointers to structs are checked as well, so the problems of the `Director` are listed with the ones of the `Movie`.
+// It returns nil when there is no problem.
+func validate(name string, v interface{}) error {
+	fields := validateStruct(reflect.ValueOf(v), "")
+	if len(fields) == 0 {
+		return nil
+	}
+	return &validationError{Message: "invalid " + name, Fields: fields}
+}
+
+func validateStruct(v reflect.Value, prefix string) []fieldError {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	var problems []fieldError
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := prefix + strings.Split(field.Tag.Get("json"), ",")[0]
+		value := v.Field(i)
+
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "" {
+				continue
+			}
+			rule, arg, _ := strings.Cut(rule, "=")
+			check, ok := rules[rule]
+			if !ok {
+				// a typo in a tag is a bug of the server and not a problem of the body
+				panic(fmt.Sprintf("unknown validation rule %q on %s.%s", rule, t.Name(), field.Name))
+			}
+			if problem := check(value, arg); problem != "" {
+				problems = append(problems, fieldError{Field: name, Message: problem})
+				// the other rules of a missing field would only repeat the problem
+				break
+			}
+		}
+		// nested structs like the `Director` are checked with their own tags
+		if value.Kind() == reflect.Pointer && value.Type().Elem().Kind() == reflect.Struct {
+			problems = append(problems, validateStruct(value, name+".")...)
+		}
+	}
+	return problems
+}
+
+// removes the hyphens and spaces which are often written in an ISBN, e.g. "978-0-306-40615-7"
+func cleanIsbn(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+}
+
+// checks the length and the check digit of an ISBN-10 or ISBN-13
+func validIsbn(isbn string) bool {
+	isbn = cleanIsbn(isbn)
+	switch len(isbn) {
+	case 10:
+		// the digits are weighted 10 down to 1 and the sum must be divisible by 11, the last digit may be X for 10
+		sum := 0
+		for i, c := range isbn {
+			digit := int(c - '0')
+			if c == 'X' && i == 9 {
+				digit = 10
+			} else if c < '0' || c > '9' {
+				return false
+			}
+			sum += digit * (10 - i)
+		}
+		return sum%11 == 0
+	case 13:
+		// the digits are weighted 1 and 3 in turns and the sum must be divisible by 10
+		sum := 0
+		for i, c := range isbn {
+			if c < '0' || c > '9' {
+				return false
+			}
+			weight := 1
+			if i%2 == 1 {
+				weight = 3
+			}
+			sum += int(c-'0') * weight
+		}
+		return sum%10 == 0
+	}
+	return false
+}
+
+// returns the ISBN-13 form of an ISBN, so the ISBN-10 and the ISBN-13 of the same book are seen as the same ISBN.
+// An ISBN-10 becomes an ISBN-13 by putting 978 in front of it and computing the check digit again.
+func canonicalIsbn(isbn string) string {
+	isbn = cleanIsbn(isbn)
+	if len(isbn) != 10 || !validIsbn(isbn) {
+		return isbn
+	}
+	isbn = "978" + isbn[:9]
+	sum := 0
+	for i, c := range isbn {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(c-'0') * weight
+	}
+	return isbn + strconv.Itoa((10-sum%10)%10)
+}
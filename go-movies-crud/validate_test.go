@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidIsbn(t *testing.T) {
+	for _, c := range []struct {
+		isbn string
+		want bool
+	}{
+		{"9780306406157", true},
+		{"0306406152", true},
+		{"080442957X", true},
+		{"080442957x", true}, // the check digit X may be written in lower case
+		{"978-0-306-40615-7", true},
+		{"0 306 40615 2", true},
+		{"978 0-306 40615-7", true},
+		{"9780306406158", false}, // wrong check digit
+		{"0306406153", false},
+		{"0804429570", false}, // the check digit of this one is X
+		{"08044295X7", false}, // X is only a check digit
+		{"978030640615X", false},
+		{"978030640615", false}, // too short
+		{"97803064061570", false},
+		{"978-0-306-4061a-7", false},
+		{"", false},
+	} {
+		if got := validIsbn(c.isbn); got != c.want {
+			t.Errorf("validIsbn(%q) = %t, want %t", c.isbn, got, c.want)
+		}
+	}
+}
+
+func TestCanonicalIsbn(t *testing.T) {
+	for _, c := range []struct {
+		isbn, want string
+	}{
+		{"0306406152", "9780306406157"},
+		{"0-306-40615-2", "9780306406157"},
+		{"080442957X", "9780804429573"},
+		{"9780306406157", "9780306406157"},
+		{"978-0-306-40615-7", "9780306406157"},
+		// an invalid ISBN-10 is only cleaned, so it can't clash with the ISBN-13 of another book
+		{"0306406153", "0306406153"},
+	} {
+		if got := canonicalIsbn(c.isbn); got != c.want {
+			t.Errorf("canonicalIsbn(%q) = %s, want %s", c.isbn, got, c.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Movie{Isbn: "9780306406157", Title: "Movie One", Director: &Director{Firstname: "John", Lastname: "Doe"}}
+	if err := validate("movie", valid); err != nil {
+		t.Errorf("validate of a valid movie = %v", err)
+	}
+	for _, c := range []struct {
+		name   string
+		movie  Movie
+		fields []fieldError
+	}{
+		{
+			// the problems of the director are not listed when there is no director
+			name:   "nil director",
+			movie:  Movie{Isbn: "9780306406157", Title: "Movie One"},
+			fields: []fieldError{{"director", "is required"}},
+		},
+		{
+			name:  "every field",
+			movie: Movie{Isbn: "123", Title: "  ", Director: &Director{Lastname: strings.Repeat("é", 101)}},
+			fields: []fieldError{
+				{"isbn", "must be a valid ISBN-10 or ISBN-13"},
+				{"title", "is required"},
+				{"director.firstname", "is required"},
+				{"director.lastname", "must be at most 100 characters"},
+			},
+		},
+		{
+			// only the first problem of a field is listed
+			name:   "missing isbn",
+			movie:  Movie{Title: strings.Repeat("a", 200), Director: valid.Director},
+			fields: []fieldError{{"isbn", "is required"}},
+		},
+	} {
+		err := validate("movie", c.movie)
+		var verr *validationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: validate = %v, want a validationError", c.name, err)
+			continue
+		}
+		if verr.Message != "invalid movie" || !reflect.DeepEqual(verr.Fields, c.fields) {
+			t.Errorf("%s: validate = %+v, want %+v", c.name, verr, c.fields)
+		}
+	}
+}